@@ -0,0 +1,227 @@
+package behaviors
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/dates"
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+	gomarathon "github.com/gambol99/go-marathon"
+)
+
+// The things `action` can do to the app in `app_id` instead of deploying
+// `app_json`.
+const (
+	actionDeploy  = ""
+	actionScale   = "scale"
+	actionRestart = "restart"
+	actionSuspend = "suspend"
+	actionResume  = "resume"
+	actionDestroy = "destroy"
+)
+
+// labelSuspendedInstances is where suspend keeps the instance count for
+// resume.
+const labelSuspendedInstances = "MARATHON_RESOURCE_SUSPENDED_INSTANCES"
+
+// runAction runs `action` against the app in `app_id` and waits for its
+// deployment.
+func runAction(input InputJSON, apiclient marathon.Marathoner) (IOOutput, error) {
+	appID := input.Source.AppID
+	switch input.Source.Kind {
+	case "", kindApp:
+	default:
+		return IOOutput{}, fmt.Errorf(
+			"action %s is only supported for apps, not %ss",
+			input.Params.Action,
+			input.Source.Kind,
+		)
+	}
+
+	var (
+		did       gomarathon.DeploymentID
+		instances int
+		version   string
+		err       error
+	)
+	switch input.Params.Action {
+	case actionScale:
+		instances = *input.Params.Instances
+		did, err = apiclient.ScaleApp(appID, instances)
+	case actionRestart:
+		did, err = apiclient.RestartApp(appID)
+	case actionSuspend:
+		did, err = suspendApp(appID, apiclient)
+	case actionResume:
+		did, instances, err = resumeApp(appID, apiclient)
+	case actionDestroy:
+		// The app's versions go with it, so the version is the one it was
+		// running.
+		var app gomarathon.Application
+		if app, err = apiclient.GetApp(appID, ""); err == nil {
+			version = app.Version
+			did, err = apiclient.DeleteApp(appID)
+		}
+	}
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	if err = checkDeploymentLoop(
+		did.DeploymentID,
+		appID,
+		input.Params,
+		apiclient,
+	); err != nil {
+		return IOOutput{}, err
+	}
+
+	metadata := []Metadata{{"action", input.Params.Action}}
+	switch input.Params.Action {
+	case actionScale, actionResume:
+		metadata = append(metadata, Metadata{"instances", strconv.Itoa(instances)})
+	}
+	if version == "" {
+		version = did.Version
+	}
+	return IOOutput{Version: Version{Ref: version}, Metadata: metadata}, nil
+}
+
+// rollbackApp redeploys the app in `app_id` as it was at the `rollback_to`
+// version. The version is checked before anything is deployed.
+func rollbackApp(
+	input InputJSON,
+	dir string,
+	apiclient marathon.Marathoner,
+) (IOOutput, error) {
+	appID := input.Source.AppID
+	switch input.Source.Kind {
+	case "", kindApp:
+	default:
+		return IOOutput{}, fmt.Errorf(
+			"rollback_to is only supported for apps, not %ss",
+			input.Source.Kind,
+		)
+	}
+
+	version, err := rollbackVersion(input.Params.RollbackTo, dir)
+	if err != nil {
+		return IOOutput{}, err
+	}
+	if _, err = dates.ParseTimestamp(version); err != nil {
+		return IOOutput{}, fmt.Errorf("Invalid rollback_to version %q: %v", version, err)
+	}
+	// Either fails when the app never had the version.
+	if _, err = apiclient.LatestVersions(appID, version); err == nil {
+		_, err = apiclient.GetAppJSON(appID, version)
+	}
+	if err != nil {
+		return IOOutput{}, withCause(err, "Version %s of %s not found: %v", version, appID, err)
+	}
+
+	did, err := apiclient.RollbackApp(appID, version)
+	if err != nil {
+		return IOOutput{}, err
+	}
+	if err = checkDeploymentLoop(
+		did.DeploymentID,
+		appID,
+		input.Params,
+		apiclient,
+	); err != nil {
+		return IOOutput{}, err
+	}
+
+	return IOOutput{
+		Version:  Version{Ref: did.Version},
+		Metadata: []Metadata{{"rolled_back_to", version}},
+	}, nil
+}
+
+// rollbackVersion returns the `rollback_to` version. It can also be the path
+// of a file holding the version, like the one written by `get`.
+func rollbackVersion(rollbackTo string, dir string) (string, error) {
+	version, err := ioutil.ReadFile(filepath.Join(dir, rollbackTo))
+	if os.IsNotExist(err) {
+		return strings.TrimSpace(rollbackTo), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(version)), nil
+}
+
+// suspendApp scales the app to 0 instances and keeps how many it had in a
+// label.
+func suspendApp(appID string, apiclient marathon.Marathoner) (gomarathon.DeploymentID, error) {
+	app, err := apiclient.GetApp(appID, "")
+	if err != nil {
+		return gomarathon.DeploymentID{}, err
+	}
+	labels := appLabels(app)
+	if _, ok := labels[labelSuspendedInstances]; ok && instances(app) == 0 {
+		return gomarathon.DeploymentID{}, fmt.Errorf("App %s is already suspended", appID)
+	}
+	labels[labelSuspendedInstances] = strconv.Itoa(instances(app))
+	return updateInstances(appID, 0, labels, apiclient)
+}
+
+// resumeApp scales the app back to the instance count suspend kept and
+// removes the label.
+func resumeApp(appID string, apiclient marathon.Marathoner) (gomarathon.DeploymentID, int, error) {
+	app, err := apiclient.GetApp(appID, "")
+	if err != nil {
+		return gomarathon.DeploymentID{}, 0, err
+	}
+	labels := appLabels(app)
+	saved, ok := labels[labelSuspendedInstances]
+	if !ok {
+		return gomarathon.DeploymentID{}, 0, fmt.Errorf("App %s is not suspended", appID)
+	}
+	n, err := strconv.Atoi(saved)
+	if err != nil {
+		return gomarathon.DeploymentID{}, 0, fmt.Errorf(
+			"Invalid %s %q on %s: %v",
+			labelSuspendedInstances,
+			saved,
+			appID,
+			err,
+		)
+	}
+	delete(labels, labelSuspendedInstances)
+	did, err := updateInstances(appID, n, labels, apiclient)
+	return did, n, err
+}
+
+// updateInstances sets the app's instances and labels. Marathon replaces
+// labels as a whole so all of them are sent.
+func updateInstances(
+	appID string,
+	instances int,
+	labels map[string]string,
+	apiclient marathon.Marathoner,
+) (gomarathon.DeploymentID, error) {
+	update, err := encodeDefinition(struct {
+		Instances int               `json:"instances"`
+		Labels    map[string]string `json:"labels"`
+	}{instances, labels})
+	if err != nil {
+		return gomarathon.DeploymentID{}, err
+	}
+	return apiclient.UpdateApp(appID, update, false)
+}
+
+// appLabels returns a copy of the app's labels.
+func appLabels(app gomarathon.Application) map[string]string {
+	labels := map[string]string{}
+	if app.Labels != nil {
+		for k, v := range *app.Labels {
+			labels[k] = v
+		}
+	}
+	return labels
+}
@@ -0,0 +1,283 @@
+package behaviors
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
+	gomarathon "github.com/gambol99/go-marathon"
+	"github.com/golang/mock/gomock"
+)
+
+func Test_runAction(t *testing.T) {
+	var (
+		three     = 3
+		zero      = 0
+		did       = gomarathon.DeploymentID{DeploymentID: "dep", Version: "v2"}
+		running   = gomarathon.Application{ID: "/foo", Instances: &three, Labels: &map[string]string{"team": "a"}}
+		suspended = gomarathon.Application{
+			ID:        "/foo",
+			Instances: &zero,
+			Labels:    &map[string]string{"team": "a", labelSuspendedInstances: "3"},
+		}
+		waits = func(m *mocks.MockMarathoner) *gomock.Call {
+			return m.EXPECT().WaitDeployment("dep", gomock.Any()).Times(1).Return(false, nil)
+		}
+	)
+	tests := []struct {
+		name      string
+		action    string
+		instances *int
+		kind      string
+		expect    func(m *mocks.MockMarathoner)
+		want      IOOutput
+		wantErr   bool
+	}{
+		{
+			"Scale",
+			actionScale,
+			&three,
+			"",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().ScaleApp("foo", 3).Times(1).Return(did, nil),
+					waits(m),
+				)
+			},
+			IOOutput{Version: Version{Ref: "v2"}, Metadata: []Metadata{{"action", "scale"}, {"instances", "3"}}},
+			false,
+		},
+		{
+			"Restart",
+			actionRestart,
+			nil,
+			kindApp,
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().RestartApp("foo").Times(1).Return(did, nil),
+					waits(m),
+				)
+			},
+			IOOutput{Version: Version{Ref: "v2"}, Metadata: []Metadata{{"action", "restart"}}},
+			false,
+		},
+		{
+			"Suspend",
+			actionSuspend,
+			nil,
+			"",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().GetApp("foo", "").Times(1).Return(running, nil),
+					m.EXPECT().UpdateApp(
+						"foo",
+						json.RawMessage(`{"instances":0,"labels":{"MARATHON_RESOURCE_SUSPENDED_INSTANCES":"3","team":"a"}}`),
+						false,
+					).Times(1).Return(did, nil),
+					waits(m),
+				)
+			},
+			IOOutput{Version: Version{Ref: "v2"}, Metadata: []Metadata{{"action", "suspend"}}},
+			false,
+		},
+		{
+			"Suspend twice",
+			actionSuspend,
+			nil,
+			"",
+			func(m *mocks.MockMarathoner) {
+				m.EXPECT().GetApp("foo", "").Times(1).Return(suspended, nil)
+			},
+			IOOutput{},
+			true,
+		},
+		{
+			"Resume",
+			actionResume,
+			nil,
+			"",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().GetApp("foo", "").Times(1).Return(suspended, nil),
+					m.EXPECT().UpdateApp(
+						"foo",
+						json.RawMessage(`{"instances":3,"labels":{"team":"a"}}`),
+						false,
+					).Times(1).Return(did, nil),
+					waits(m),
+				)
+			},
+			IOOutput{Version: Version{Ref: "v2"}, Metadata: []Metadata{{"action", "resume"}, {"instances", "3"}}},
+			false,
+		},
+		{
+			"Resume without suspending",
+			actionResume,
+			nil,
+			"",
+			func(m *mocks.MockMarathoner) {
+				m.EXPECT().GetApp("foo", "").Times(1).Return(running, nil)
+			},
+			IOOutput{},
+			true,
+		},
+		{
+			"Destroy",
+			actionDestroy,
+			nil,
+			"",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().GetApp("foo", "").Times(1).Return(gomarathon.Application{Version: "v1"}, nil),
+					m.EXPECT().DeleteApp("foo").Times(1).Return(did, nil),
+					waits(m),
+				)
+			},
+			IOOutput{Version: Version{Ref: "v1"}, Metadata: []Metadata{{"action", "destroy"}}},
+			false,
+		},
+		{
+			"Destroy fails",
+			actionDestroy,
+			nil,
+			"",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().GetApp("foo", "").Times(1).Return(gomarathon.Application{Version: "v1"}, nil),
+					m.EXPECT().DeleteApp("foo").Times(1).Return(gomarathon.DeploymentID{}, errors.New("Bad stuff")),
+				)
+			},
+			IOOutput{},
+			true,
+		},
+		{
+			"Deployment fails",
+			actionScale,
+			&zero,
+			"",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().ScaleApp("foo", 0).Times(1).Return(did, nil),
+					m.EXPECT().WaitDeployment("dep", gomock.Any()).Times(1).Return(false, marathon.ErrDeploymentFailed),
+				)
+			},
+			IOOutput{},
+			true,
+		},
+		{
+			"Pods",
+			actionRestart,
+			nil,
+			kindPod,
+			func(m *mocks.MockMarathoner) {},
+			IOOutput{},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		ctrl := gomock.NewController(t)
+		mockMarathoner := mocks.NewMockMarathoner(ctrl)
+		tt.expect(mockMarathoner)
+
+		input := InputJSON{
+			Params: Params{TimeOut: 2, Action: tt.action, Instances: tt.instances},
+			Source: Source{AppID: "foo", Kind: tt.kind},
+		}
+		got, err := runAction(input, mockMarathoner)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. runAction() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. runAction() = %v, want %v", tt.name, got, tt.want)
+		}
+		ctrl.Finish()
+	}
+}
+
+func Test_rollbackApp(t *testing.T) {
+	const version = "2017-01-01T00:00:00.000Z"
+	var (
+		did      = gomarathon.DeploymentID{DeploymentID: "dep", Version: "v3"}
+		want     = IOOutput{Version: Version{Ref: "v3"}, Metadata: []Metadata{{"rolled_back_to", version}}}
+		rollback = func(m *mocks.MockMarathoner) {
+			gomock.InOrder(
+				m.EXPECT().LatestVersions("foo", version).Times(1).Return([]string{version, "v2"}, nil),
+				m.EXPECT().GetAppJSON("foo", version).Times(1).Return(json.RawMessage(`{"id":"/foo"}`), nil),
+				m.EXPECT().RollbackApp("foo", version).Times(1).Return(did, nil),
+				m.EXPECT().WaitDeployment("dep", gomock.Any()).Times(1).Return(false, nil),
+			)
+		}
+	)
+
+	dir, err := ioutil.TempDir("", "marathon-resource-rollback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = os.Mkdir(filepath.Join(dir, "previous"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "previous", versionFile), []byte(version+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		rollbackTo string
+		kind       string
+		expect     func(m *mocks.MockMarathoner)
+		want       IOOutput
+		wantErr    bool
+	}{
+		{"Version", version, "", rollback, want, false},
+		{"Version file", "previous/version", kindApp, rollback, want, false},
+		{"Malformed version", "yesterday", "", func(m *mocks.MockMarathoner) {}, IOOutput{}, true},
+		{
+			"Newer than every version",
+			version,
+			"",
+			func(m *mocks.MockMarathoner) {
+				m.EXPECT().LatestVersions("foo", version).Times(1).Return(nil, errors.New("Version not found"))
+			},
+			IOOutput{},
+			true,
+		},
+		{
+			"Missing version",
+			version,
+			"",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().LatestVersions("foo", version).Times(1).Return([]string{"v2"}, nil),
+					m.EXPECT().GetAppJSON("foo", version).Times(1).Return(nil, &marathon.APIError{StatusCode: http.StatusNotFound}),
+				)
+			},
+			IOOutput{},
+			true,
+		},
+		{"Groups", version, kindGroup, func(m *mocks.MockMarathoner) {}, IOOutput{}, true},
+	}
+	for _, tt := range tests {
+		ctrl := gomock.NewController(t)
+		mockMarathoner := mocks.NewMockMarathoner(ctrl)
+		tt.expect(mockMarathoner)
+
+		input := InputJSON{
+			Params: Params{TimeOut: 2, RollbackTo: tt.rollbackTo},
+			Source: Source{AppID: "foo", Kind: tt.kind},
+		}
+		got, err := rollbackApp(input, dir, mockMarathoner)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. rollbackApp() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. rollbackApp() = %v, want %v", tt.name, got, tt.want)
+		}
+		ctrl.Finish()
+	}
+}
@@ -2,28 +2,99 @@ package behaviors
 
 import (
 	"encoding/json"
-	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
 	"time"
 
 	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
 	gomarathon "github.com/gambol99/go-marathon"
 )
 
+// buildLog is where `out` reports what it did. Concourse shows stderr in the
+// build log while stdout is kept for the version.
+var buildLog io.Writer = os.Stderr
+
+// reportDir is where `out` writes files about what it did. The put's inputs
+// aren't written to, so they go to a new directory under it. Empty is the
+// system's temp directory.
+var reportDir = ""
+
+// The ways `on_timeout` can deal with a deployment that is still running
+// after `time_out`. Deployments are rolled back by default.
+const (
+	timeoutRollback    = "rollback"
+	timeoutForceCancel = "force_cancel"
+	timeoutLeave       = "leave"
+)
+
 //Params holds the values supported in by the concourse `params` array
 type Params struct {
-	AppJSON           string     `json:"app_json"`
-	TimeOut           int        `json:"time_out"`
-	Replacements      []Metadata `json:"replacements"`
-	ReplacementFiles  []Metadata `json:"replacement_files"`
-	RestartIfNoUpdate bool       `json:"restart_if_no_update"`
+	AppJSON           AppJSONPaths `json:"app_json"`
+	TimeOut           int          `json:"time_out"`
+	Replacements      []Metadata   `json:"replacements"`
+	ReplacementFiles  []Metadata   `json:"replacement_files"`
+	RestartIfNoUpdate bool         `json:"restart_if_no_update"`
+	OnConflict        string       `json:"on_conflict"`
+	OnTimeout         string       `json:"on_timeout"`
+	StablePeriod      int          `json:"stable_period"`
+	DryRun            bool         `json:"dry_run"`
+	Strategy          string       `json:"strategy"`
+	CanaryInstances   int          `json:"canary_instances"`
+	SoakPeriod        int          `json:"soak_period"`
+	CanaryCheckURL    string       `json:"canary_check_url"`
+	Action            string       `json:"action"`
+	Instances         *int         `json:"instances"`
+	RollbackTo        string       `json:"rollback_to"`
+	Overlays          []string     `json:"overlays"`
+	StrictTemplates   bool         `json:"strict_templates"`
 }
 
 //Source holds the values supported in by the concourse `source` array
 type Source struct {
-	AppID     string              `json:"app_id"`
-	URI       string              `json:"uri"`
-	BasicAuth *marathon.AuthCreds `json:"basic_auth"`
-	APIToken  string              `json:"api_token"`
+	AppID          string                   `json:"app_id"`
+	Kind           string                   `json:"kind"`
+	URI            URIs                     `json:"uri"`
+	BasicAuth      *marathon.AuthCreds      `json:"basic_auth"`
+	APIToken       string                   `json:"api_token"`
+	ServiceAccount *marathon.ServiceAccount `json:"service_account"`
+	Retry          *marathon.RetryPolicy    `json:"retry"`
+	TLS            *marathon.TLSConfig      `json:"tls"`
+}
+
+//URIs holds one or more Marathon URIs. It can be set from either a single
+//string or a list of strings.
+type URIs []string
+
+//UnmarshalJSON accepts either a string or a list of strings
+func (u *URIs) UnmarshalJSON(b []byte) error {
+	var uri string
+	if err := json.Unmarshal(b, &uri); err == nil {
+		*u = URIs{uri}
+		return nil
+	}
+	var uris []string
+	if err := json.Unmarshal(b, &uris); err != nil {
+		return err
+	}
+	*u = URIs(uris)
+	return nil
+}
+
+//AppJSONPaths holds one or more app_json paths or globs. It can be set from
+//either a single string or a list of strings.
+type AppJSONPaths []string
+
+//UnmarshalJSON accepts either a string or a list of strings
+func (a *AppJSONPaths) UnmarshalJSON(b []byte) error {
+	var uris URIs
+	if err := uris.UnmarshalJSON(b); err != nil {
+		return err
+	}
+	*a = AppJSONPaths(uris)
+	return nil
 }
 
 //Version maps to a concourse version
@@ -53,20 +124,154 @@ type IOOutput struct {
 	Metadata []Metadata `json:"metadata"`
 }
 
+// validate rejects params with values Out doesn't understand.
+func (p Params) validate() error {
+	switch p.OnConflict {
+	case conflictFail, conflictWait, conflictCancel, conflictForce:
+	default:
+		return fmt.Errorf("Unknown on_conflict %q", p.OnConflict)
+	}
+	switch p.OnTimeout {
+	case "", timeoutRollback, timeoutForceCancel, timeoutLeave:
+	default:
+		return fmt.Errorf("Unknown on_timeout %q", p.OnTimeout)
+	}
+	switch p.Strategy {
+	case strategyRolling, strategyBlueGreen, strategyCanary:
+	default:
+		return fmt.Errorf("Unknown strategy %q", p.Strategy)
+	}
+	switch p.Action {
+	case actionScale:
+		if p.Instances == nil || *p.Instances < 0 {
+			return fmt.Errorf("action %s needs instances set to 0 or more", actionScale)
+		}
+	case actionDeploy, actionRestart, actionSuspend, actionResume, actionDestroy:
+	default:
+		return fmt.Errorf("Unknown action %q", p.Action)
+	}
+	if p.RollbackTo != "" && p.Action != actionDeploy {
+		return fmt.Errorf("rollback_to can't be used with action %s", p.Action)
+	}
+	if p.RollbackTo != "" && p.DryRun {
+		return fmt.Errorf("rollback_to can't be used with dry_run")
+	}
+	if p.DryRun && p.Action != actionDeploy {
+		return fmt.Errorf("dry_run can't be used with action %s", p.Action)
+	}
+	return nil
+}
+
 // Out shall deploy an APP to marathon based on marathon.json file.
 func Out(input InputJSON, appJSONPath string, apiclient marathon.Marathoner) (IOOutput, error) {
+	output, err := deploy(input, appJSONPath, apiclient)
+	if derr, ok := err.(*deploymentError); ok {
+		reportDiagnostics(derr, apiclient)
+	}
+	return output, err
+}
+
+func deploy(input InputJSON, appJSONPath string, apiclient marathon.Marathoner) (IOOutput, error) {
+
+	if err := input.Params.validate(); err != nil {
+		return IOOutput{}, err
+	}
+	if input.Params.Action != actionDeploy {
+		return runAction(input, apiclient)
+	}
+	if input.Params.RollbackTo != "" {
+		return rollbackApp(input, appJSONPath, apiclient)
+	}
 
-	jsondata, err := parsePayload(input.Params, appJSONPath)
+	files, err := appJSONFiles(input.Params.AppJSON, appJSONPath)
 	if err != nil {
 		return IOOutput{}, err
 	}
+	if err = checkStrict(input.Params, appJSONPath, files); err != nil {
+		return IOOutput{}, err
+	}
+	if len(files) > 1 {
+		return deployRelease(input, files, appJSONPath, apiclient)
+	}
 
-	var marathonAPP gomarathon.Application
-	if err = json.NewDecoder(jsondata).Decode(&marathonAPP); err != nil {
+	jsondata, err := parsePayload(input.Params, appJSONPath, files[0])
+	if err != nil {
 		return IOOutput{}, err
 	}
 
-	did, err := apiclient.UpdateApp(marathonAPP)
+	appJSON, err := ioutil.ReadAll(jsondata)
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	// Only a few fields are needed here. The rendered document is sent to
+	// Marathon untouched so fields go-marathon doesn't model aren't dropped.
+	var marathonAPP definition
+	if err = json.Unmarshal(appJSON, &marathonAPP); err != nil {
+		return IOOutput{}, err
+	}
+
+	kind := marathonAPP.kind(input.Source.Kind)
+	if input.Params.DryRun {
+		if kind != kindApp {
+			return IOOutput{}, fmt.Errorf("dry_run is only supported for apps, not %ss", kind)
+		}
+		return dryRun(marathonAPP.ID, appJSON, apiclient)
+	}
+	if input.Params.Strategy != strategyRolling && kind != kindApp {
+		return IOOutput{}, fmt.Errorf(
+			"%s is only supported for apps, not %ss",
+			input.Params.Strategy,
+			kind,
+		)
+	}
+	switch input.Params.Strategy {
+	case strategyBlueGreen:
+		return deployBlueGreen(input, marathonAPP.ID, appJSON, apiclient)
+	case strategyCanary:
+		return deployCanary(input, marathonAPP.ID, appJSON, apiclient)
+	}
+
+	switch kind {
+	case kindGroup:
+		return deployDefinition(
+			input,
+			kind,
+			marathonAPP.ID,
+			appJSON,
+			apiclient.UpdateGroup,
+			apiclient.LatestGroupVersions,
+			apiclient,
+		)
+	case kindPod:
+		return deployDefinition(
+			input,
+			kind,
+			marathonAPP.ID,
+			appJSON,
+			apiclient.UpdatePod,
+			apiclient.LatestPodVersions,
+			apiclient,
+		)
+	}
+
+	return deployApp(input, marathonAPP.ID, appJSON, apiclient)
+}
+
+// deployApp updates an app in place and returns its new version.
+func deployApp(
+	input InputJSON,
+	appID string,
+	appJSON []byte,
+	apiclient marathon.Marathoner,
+) (IOOutput, error) {
+	did, err := updateOnConflict(
+		input,
+		appID,
+		appJSON,
+		apiclient.UpdateApp,
+		apiclient,
+	)
 
 	if err != nil {
 		return IOOutput{}, err
@@ -74,13 +279,14 @@ func Out(input InputJSON, appJSONPath string, apiclient marathon.Marathoner) (IO
 
 	if err = checkDeploymentLoop(
 		did.DeploymentID,
-		time.Duration(input.Params.TimeOut),
+		appID,
+		input.Params,
 		apiclient,
 	); err != nil {
 		return IOOutput{}, err
 	}
 
-	versions, err := apiclient.LatestVersions(marathonAPP.ID, "")
+	versions, err := apiclient.LatestVersions(appID, "")
 	if err != nil {
 		return IOOutput{}, err
 	}
@@ -90,12 +296,24 @@ func Out(input InputJSON, appJSONPath string, apiclient marathon.Marathoner) (IO
 			return IOOutput{Version: Version{Ref: versions[len(versions)-1]}}, nil
 		}
 
-		if did, err = apiclient.RestartApp(marathonAPP.ID); err != nil {
+		if did, err = apiclient.RestartApp(appID); err != nil {
 			return IOOutput{}, err
 		}
 		if err = checkDeploymentLoop(
 			did.DeploymentID,
-			time.Duration(input.Params.TimeOut),
+			appID,
+			input.Params,
+			apiclient,
+		); err != nil {
+			return IOOutput{}, err
+		}
+	}
+
+	if input.Params.StablePeriod > 0 {
+		if err = waitHealthy(
+			appID,
+			did.Version,
+			input.Params,
 			apiclient,
 		); err != nil {
 			return IOOutput{}, err
@@ -108,57 +326,182 @@ func Out(input InputJSON, appJSONPath string, apiclient marathon.Marathoner) (IO
 
 func checkDeploymentLoop(
 	deploymentID string,
-	timeOut time.Duration,
+	id string,
+	params Params,
 	apiclient marathon.Marathoner,
 ) error {
-	timer := time.NewTimer(timeOut * time.Second)
-	deploying := true
-
-	// Check if APP was deployed.
-deployloop:
-	for {
-
-		select {
-		case <-timer.C:
-			break deployloop
-		default:
-			var err error
-			deploying, err = apiclient.CheckDeployment(deploymentID)
-			if err != nil {
-				return err
-			}
-			if !deploying {
-				break deployloop
-			}
-		}
-		time.Sleep(1 * time.Second)
+	timeOut := time.Duration(params.TimeOut)
+	deploying, err := apiclient.WaitDeployment(deploymentID, timeOut*time.Second)
+	if err == marathon.ErrDeploymentFailed {
+		return &deploymentError{deploymentID: deploymentID, id: id, err: err}
+	}
+	if err != nil {
+		return err
 	}
 	if deploying {
-		err := apiclient.DeleteDeployment(deploymentID)
-		if err != nil {
-			return err
+		// The deployment is only needed for the diagnostics so it not being
+		// found doesn't matter.
+		deployment, _ := apiclient.GetDeployment(deploymentID)
+		return &deploymentError{
+			deploymentID: deploymentID,
+			id:           id,
+			deployment:   deployment,
+			err:          handleTimeout(deploymentID, timeOut, params.OnTimeout, apiclient),
 		}
-		return errors.New("Could not deploy")
 	}
 	return nil
 }
 
-// In shall fetch info on current version
-func In(input InputJSON, apiclient marathon.Marathoner) (IOOutput, error) {
+// handleTimeout deals with a deployment still running after `time_out` as
+// set by `on_timeout` and returns why the put failed.
+func handleTimeout(
+	deploymentID string,
+	timeOut time.Duration,
+	onTimeout string,
+	apiclient marathon.Marathoner,
+) error {
+	switch onTimeout {
+	case timeoutLeave:
+		return fmt.Errorf(
+			"Could not deploy: deployment %s timed out and was left running",
+			deploymentID,
+		)
+	case timeoutForceCancel:
+		if _, err := apiclient.DeleteDeployment(deploymentID, true); err != nil {
+			return err
+		}
+		return fmt.Errorf(
+			"Could not deploy: deployment %s timed out and was cancelled without a rollback",
+			deploymentID,
+		)
+	}
 
-	app, err := apiclient.GetApp(input.Source.AppID, input.Version.Ref)
+	rollback, err := apiclient.DeleteDeployment(deploymentID, false)
 	if err != nil {
+		return err
+	}
+	if rollback.DeploymentID == "" {
+		return fmt.Errorf(
+			"Could not deploy: deployment %s timed out and was rolled back",
+			deploymentID,
+		)
+	}
+	deploying, err := apiclient.WaitDeployment(
+		rollback.DeploymentID,
+		timeOut*time.Second,
+	)
+	switch {
+	case err == marathon.ErrDeploymentFailed:
+		return fmt.Errorf(
+			"Could not deploy: deployment %s timed out and its rollback %s failed",
+			deploymentID,
+			rollback.DeploymentID,
+		)
+	case err != nil:
+		return err
+	case deploying:
+		return fmt.Errorf(
+			"Could not deploy: deployment %s timed out and its rollback %s did not finish within %d seconds",
+			deploymentID,
+			rollback.DeploymentID,
+			timeOut,
+		)
+	}
+	return fmt.Errorf(
+		"Could not deploy: deployment %s timed out and was rolled back by %s",
+		deploymentID,
+		rollback.DeploymentID,
+	)
+}
+
+// In shall fetch info on current version and write it to the destination
+func In(
+	input InputJSON,
+	destination string,
+	apiclient marathon.Marathoner,
+) (IOOutput, error) {
+
+	switch input.Source.Kind {
+	case kindGroup:
+		return fetchDefinition(
+			input,
+			destination,
+			apiclient.GetGroupJSON,
+			groupFile,
+			groupMetadata,
+		)
+	case kindPod:
+		return fetchDefinition(
+			input,
+			destination,
+			apiclient.GetPodJSON,
+			podFile,
+			podMetadata,
+		)
+	}
+
+	var appJSON json.RawMessage
+	err := followColor(input.Source.AppID, apiclient, func(id string) (err error) {
+		appJSON, err = apiclient.GetAppJSON(id, input.Version.Ref)
+		return err
+	})
+	if apiErr, ok := err.(*marathon.APIError); ok && apiErr.StatusCode == http.StatusNotFound && input.Version.Ref != "" {
+		// A destroyed app takes its versions with it. Only the version is
+		// written so the get after `action: destroy` still works.
+		output := IOOutput{
+			Version:  input.Version,
+			Metadata: []Metadata{{"destroyed", "true"}},
+		}
+		return output, writeInFiles(destination, nil, output)
+	}
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	var app gomarathon.Application
+	if err = json.Unmarshal(appJSON, &app); err != nil {
+		return IOOutput{}, err
+	}
+
+	promotable, err := promotableApp(appJSON)
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	output := IOOutput{
+		Version:  Version{Ref: app.Version},
+		Metadata: appMetadata(app),
+	}
+	if err = writeInFiles(
+		destination,
+		map[string][]byte{appFile: appJSON, promotableAppFile: promotable},
+		output,
+	); err != nil {
 		return IOOutput{}, err
 	}
 
-	return IOOutput{Version: Version{Ref: app.Version}}, nil
+	return output, nil
 
 }
 
 // Check shall get the latest versions
 func Check(input InputJSON, apiclient marathon.Marathoner) (CheckOutput, error) {
 
-	versions, err := apiclient.LatestVersions(input.Source.AppID, input.Version.Ref)
+	var (
+		versions []string
+		err      error
+	)
+	switch input.Source.Kind {
+	case kindGroup:
+		versions, err = apiclient.LatestGroupVersions(input.Source.AppID, input.Version.Ref)
+	case kindPod:
+		versions, err = apiclient.LatestPodVersions(input.Source.AppID, input.Version.Ref)
+	default:
+		err = followColor(input.Source.AppID, apiclient, func(id string) (err error) {
+			versions, err = apiclient.LatestVersions(id, input.Version.Ref)
+			return err
+		})
+	}
 	if err != nil {
 		return CheckOutput{}, err
 	}
@@ -1,9 +1,15 @@
 package behaviors
 
 import (
+	"encoding/json"
 	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
 	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
@@ -17,26 +23,27 @@ func TestOut(t *testing.T) {
 		mockMarathoner = mocks.NewMockMarathoner(ctrl)
 	)
 	defer ctrl.Finish()
+	defer allowDiagnostics(t, mockMarathoner)()
 
 	gomock.InOrder(
-		mockMarathoner.EXPECT().UpdateApp(gomock.Any()).Times(6).Return(gomarathon.DeploymentID{DeploymentID: "foo", Version: "bar"}, nil),
-		mockMarathoner.EXPECT().UpdateApp(gomock.Any()).Times(1).Return(gomarathon.DeploymentID{}, errors.New("Something went wrong")),
-		mockMarathoner.EXPECT().UpdateApp(gomock.Any()).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "baz", Version: "bar"}, nil),
-		mockMarathoner.EXPECT().UpdateApp(gomock.Any()).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "quux", Version: "bar"}, nil),
-		mockMarathoner.EXPECT().UpdateApp(gomock.Any()).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "zork", Version: "bar"}, nil),
+		mockMarathoner.EXPECT().UpdateApp(gomock.Any(), gomock.Any(), false).Times(6).Return(gomarathon.DeploymentID{DeploymentID: "foo", Version: "bar"}, nil),
+		mockMarathoner.EXPECT().UpdateApp(gomock.Any(), gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{}, errors.New("Something went wrong")),
+		mockMarathoner.EXPECT().UpdateApp(gomock.Any(), gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "baz", Version: "bar"}, nil),
+		mockMarathoner.EXPECT().UpdateApp(gomock.Any(), gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "quux", Version: "bar"}, nil),
+		mockMarathoner.EXPECT().UpdateApp(gomock.Any(), gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "zork", Version: "bar"}, nil),
 	)
 	gomock.InOrder(
-		mockMarathoner.EXPECT().CheckDeployment("foo").Times(3).Return(false, nil),
-		mockMarathoner.EXPECT().CheckDeployment("bing").Times(1).Return(false, nil),
-		mockMarathoner.EXPECT().CheckDeployment("foo").Times(3).Return(false, nil),
-		mockMarathoner.EXPECT().CheckDeployment("bing").Times(1).Return(false, errors.New("something bad happened")),
-		mockMarathoner.EXPECT().CheckDeployment("baz").Times(2).Return(true, nil),
-		mockMarathoner.EXPECT().CheckDeployment("quux").Times(1).Return(false, errors.New("something bad happened")),
-		mockMarathoner.EXPECT().CheckDeployment("zork").Times(2).Return(true, nil),
+		mockMarathoner.EXPECT().WaitDeployment("foo", 2*time.Second).Times(3).Return(false, nil),
+		mockMarathoner.EXPECT().WaitDeployment("bing", 2*time.Second).Times(1).Return(false, nil),
+		mockMarathoner.EXPECT().WaitDeployment("foo", 2*time.Second).Times(3).Return(false, nil),
+		mockMarathoner.EXPECT().WaitDeployment("bing", 2*time.Second).Times(1).Return(false, errors.New("something bad happened")),
+		mockMarathoner.EXPECT().WaitDeployment("baz", 2*time.Second).Times(1).Return(true, nil),
+		mockMarathoner.EXPECT().WaitDeployment("quux", 2*time.Second).Times(1).Return(false, marathon.ErrDeploymentFailed),
+		mockMarathoner.EXPECT().WaitDeployment("zork", 2*time.Second).Times(1).Return(true, nil),
 	)
 	gomock.InOrder(
-		mockMarathoner.EXPECT().DeleteDeployment("baz").Times(1).Return(nil),
-		mockMarathoner.EXPECT().DeleteDeployment("zork").Times(1).Return(errors.New("no way")),
+		mockMarathoner.EXPECT().DeleteDeployment("baz", false).Times(1).Return(gomarathon.DeploymentID{}, nil),
+		mockMarathoner.EXPECT().DeleteDeployment("zork", false).Times(1).Return(gomarathon.DeploymentID{}, errors.New("no way")),
 	)
 	gomock.InOrder(
 		mockMarathoner.EXPECT().RestartApp(gomock.Any()).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "bing", Version: "bar"}, nil),
@@ -66,7 +73,7 @@ func TestOut(t *testing.T) {
 			"Works",
 			args{
 				input: InputJSON{
-					Params: Params{AppJSON: "app.json", TimeOut: 2},
+					Params: Params{AppJSON: AppJSONPaths{"app.json"}, TimeOut: 2},
 					Source: Source{},
 				},
 				appJSONPath: "../fixtures",
@@ -79,7 +86,7 @@ func TestOut(t *testing.T) {
 			"No update",
 			args{
 				input: InputJSON{
-					Params: Params{AppJSON: "app.json", TimeOut: 2},
+					Params: Params{AppJSON: AppJSONPaths{"app.json"}, TimeOut: 2},
 					Source: Source{},
 				},
 				appJSONPath: "../fixtures",
@@ -92,7 +99,7 @@ func TestOut(t *testing.T) {
 			"No update, restart",
 			args{
 				input: InputJSON{
-					Params: Params{AppJSON: "app.json", TimeOut: 2, RestartIfNoUpdate: true},
+					Params: Params{AppJSON: AppJSONPaths{"app.json"}, TimeOut: 2, RestartIfNoUpdate: true},
 					Source: Source{},
 				},
 				appJSONPath: "../fixtures",
@@ -105,7 +112,7 @@ func TestOut(t *testing.T) {
 			"Errors fetching latest versions",
 			args{
 				input: InputJSON{
-					Params: Params{AppJSON: "app.json", TimeOut: 2, RestartIfNoUpdate: true},
+					Params: Params{AppJSON: AppJSONPaths{"app.json"}, TimeOut: 2, RestartIfNoUpdate: true},
 					Source: Source{},
 				},
 				appJSONPath: "../fixtures",
@@ -118,7 +125,7 @@ func TestOut(t *testing.T) {
 			"Errors restarting app",
 			args{
 				input: InputJSON{
-					Params: Params{AppJSON: "app.json", TimeOut: 2, RestartIfNoUpdate: true},
+					Params: Params{AppJSON: AppJSONPaths{"app.json"}, TimeOut: 2, RestartIfNoUpdate: true},
 					Source: Source{},
 				},
 				appJSONPath: "../fixtures",
@@ -131,7 +138,7 @@ func TestOut(t *testing.T) {
 			"Errors on second deployment check",
 			args{
 				input: InputJSON{
-					Params: Params{AppJSON: "app.json", TimeOut: 2, RestartIfNoUpdate: true},
+					Params: Params{AppJSON: AppJSONPaths{"app.json"}, TimeOut: 2, RestartIfNoUpdate: true},
 					Source: Source{},
 				},
 				appJSONPath: "../fixtures",
@@ -144,7 +151,7 @@ func TestOut(t *testing.T) {
 			"Bad app json file",
 			args{
 				input: InputJSON{
-					Params: Params{AppJSON: "ajson", TimeOut: 2},
+					Params: Params{AppJSON: AppJSONPaths{"ajson"}, TimeOut: 2},
 					Source: Source{},
 				},
 				appJSONPath: "../fixtures",
@@ -157,7 +164,7 @@ func TestOut(t *testing.T) {
 			"Bad app json file",
 			args{
 				input: InputJSON{
-					Params: Params{AppJSON: "app_bad.json", TimeOut: 2},
+					Params: Params{AppJSON: AppJSONPaths{"app_bad.json"}, TimeOut: 2},
 					Source: Source{},
 				},
 				appJSONPath: "../fixtures",
@@ -170,7 +177,7 @@ func TestOut(t *testing.T) {
 			"Error from UpdateApp",
 			args{
 				input: InputJSON{
-					Params: Params{AppJSON: "app.json", TimeOut: 2},
+					Params: Params{AppJSON: AppJSONPaths{"app.json"}, TimeOut: 2},
 					Source: Source{},
 				},
 				appJSONPath: "../fixtures",
@@ -183,7 +190,7 @@ func TestOut(t *testing.T) {
 			"Deployment times out",
 			args{
 				input: InputJSON{
-					Params: Params{AppJSON: "app.json", TimeOut: 2},
+					Params: Params{AppJSON: AppJSONPaths{"app.json"}, TimeOut: 2},
 					Source: Source{},
 				},
 				appJSONPath: "../fixtures",
@@ -196,7 +203,7 @@ func TestOut(t *testing.T) {
 			"Check deployment errors",
 			args{
 				input: InputJSON{
-					Params: Params{AppJSON: "app.json", TimeOut: 2},
+					Params: Params{AppJSON: AppJSONPaths{"app.json"}, TimeOut: 2},
 					Source: Source{},
 				},
 				appJSONPath: "../fixtures",
@@ -209,7 +216,7 @@ func TestOut(t *testing.T) {
 			"Delete deployment errors",
 			args{
 				input: InputJSON{
-					Params: Params{AppJSON: "app.json", TimeOut: 2},
+					Params: Params{AppJSON: AppJSONPaths{"app.json"}, TimeOut: 2},
 					Source: Source{},
 				},
 				appJSONPath: "../fixtures",
@@ -232,6 +239,110 @@ func TestOut(t *testing.T) {
 	}
 }
 
+func Test_handleTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		onTimeout string
+		expect    func(m *mocks.MockMarathoner)
+		wantErr   string
+	}{
+		{
+			"Rolls back by default",
+			"",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().DeleteDeployment("foo", false).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "back"}, nil),
+					m.EXPECT().WaitDeployment("back", 2*time.Second).Times(1).Return(false, nil),
+				)
+			},
+			"Could not deploy: deployment foo timed out and was rolled back by back",
+		},
+		{
+			"Rollback fails",
+			timeoutRollback,
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().DeleteDeployment("foo", false).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "back"}, nil),
+					m.EXPECT().WaitDeployment("back", 2*time.Second).Times(1).Return(false, marathon.ErrDeploymentFailed),
+				)
+			},
+			"Could not deploy: deployment foo timed out and its rollback back failed",
+		},
+		{
+			"Rollback times out",
+			timeoutRollback,
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().DeleteDeployment("foo", false).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "back"}, nil),
+					m.EXPECT().WaitDeployment("back", 2*time.Second).Times(1).Return(true, nil),
+				)
+			},
+			"Could not deploy: deployment foo timed out and its rollback back did not finish within 2 seconds",
+		},
+		{
+			"Rollback can't be started",
+			timeoutRollback,
+			func(m *mocks.MockMarathoner) {
+				m.EXPECT().DeleteDeployment("foo", false).Times(1).Return(gomarathon.DeploymentID{}, errors.New("no way"))
+			},
+			"no way",
+		},
+		{
+			"Force cancel",
+			timeoutForceCancel,
+			func(m *mocks.MockMarathoner) {
+				m.EXPECT().DeleteDeployment("foo", true).Times(1).Return(gomarathon.DeploymentID{}, nil)
+			},
+			"Could not deploy: deployment foo timed out and was cancelled without a rollback",
+		},
+		{
+			"Leave",
+			timeoutLeave,
+			func(m *mocks.MockMarathoner) {},
+			"Could not deploy: deployment foo timed out and was left running",
+		},
+	}
+	for _, tt := range tests {
+		ctrl := gomock.NewController(t)
+		mockMarathoner := mocks.NewMockMarathoner(ctrl)
+		tt.expect(mockMarathoner)
+
+		err := handleTimeout("foo", 2, tt.onTimeout, mockMarathoner)
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%q. handleTimeout() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestParams_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  Params
+		wantErr bool
+	}{
+		{"Defaults", Params{}, false},
+		{"Known modes", Params{OnConflict: conflictCancel, OnTimeout: timeoutLeave}, false},
+		{"Unknown on_conflict", Params{OnConflict: "shrug"}, true},
+		{"Unknown on_timeout", Params{OnTimeout: "shrug"}, true},
+		{"Blue/green", Params{Strategy: strategyBlueGreen}, false},
+		{"Canary", Params{Strategy: strategyCanary}, false},
+		{"Action", Params{Action: actionSuspend}, false},
+		{"Scale", Params{Action: actionScale, Instances: new(int)}, false},
+		{"Scale without instances", Params{Action: actionScale}, true},
+		{"Unknown action", Params{Action: "shrug"}, true},
+		{"Rollback with an action", Params{Action: actionRestart, RollbackTo: "v1"}, true},
+		{"Rollback with a dry run", Params{RollbackTo: "v1", DryRun: true}, true},
+		{"Dry run with an action", Params{Action: actionDestroy, DryRun: true}, true},
+		{"Unknown strategy", Params{Strategy: "shrug"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.params.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%q. Params.validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
 func TestIn(t *testing.T) {
 	var (
 		ctrl           = gomock.NewController(t)
@@ -239,14 +350,25 @@ func TestIn(t *testing.T) {
 	)
 	defer ctrl.Finish()
 
+	dir, err := ioutil.TempDir("", "marathon-resource-in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
 	gomock.InOrder(
-		mockMarathoner.EXPECT().GetApp("bar", "foo").Times(1).Return(gomarathon.Application{Version: "foo"}, nil),
-		mockMarathoner.EXPECT().GetApp("baz", "quux").Times(1).Return(gomarathon.Application{}, errors.New("Bad stuff")),
+		mockMarathoner.EXPECT().GetAppJSON("bar", "foo").Times(1).Return(json.RawMessage(`{"id":"/bar","version":"foo"}`), nil),
+		mockMarathoner.EXPECT().GetAppJSON("baz", "quux").Times(1).Return(nil, errors.New("Bad stuff")),
+		mockMarathoner.EXPECT().GetAppJSON("zork", "quux").Times(1).Return(json.RawMessage(`{]`), nil),
+		mockMarathoner.EXPECT().GetAppJSON("gone", "foo").Times(1).Return(nil, &marathon.APIError{StatusCode: http.StatusNotFound}),
+		mockMarathoner.EXPECT().GetAppJSON("/gone-blue", "").Times(1).Return(nil, &marathon.APIError{StatusCode: http.StatusNotFound}),
+		mockMarathoner.EXPECT().GetAppJSON("/gone-green", "").Times(1).Return(nil, &marathon.APIError{StatusCode: http.StatusNotFound}),
 	)
 
 	type args struct {
-		input     InputJSON
-		apiclient marathon.Marathoner
+		input       InputJSON
+		destination string
+		apiclient   marathon.Marathoner
 	}
 	tests := []struct {
 		name    string
@@ -261,9 +383,16 @@ func TestIn(t *testing.T) {
 					Source:  Source{AppID: "bar"},
 					Version: Version{Ref: "foo"},
 				},
-				apiclient: mockMarathoner,
+				destination: dir,
+				apiclient:   mockMarathoner,
+			},
+			IOOutput{
+				Version: Version{Ref: "foo"},
+				Metadata: []Metadata{
+					{Name: "id", Value: "/bar"},
+					{Name: "version", Value: "foo"},
+				},
 			},
-			IOOutput{Version: Version{Ref: "foo"}},
 			false,
 		},
 		{
@@ -273,14 +402,44 @@ func TestIn(t *testing.T) {
 					Source:  Source{AppID: "baz"},
 					Version: Version{Ref: "quux"},
 				},
-				apiclient: mockMarathoner,
+				destination: dir,
+				apiclient:   mockMarathoner,
+			},
+			IOOutput{},
+			true,
+		},
+		{
+			"Bad app JSON",
+			args{
+				input: InputJSON{
+					Source:  Source{AppID: "zork"},
+					Version: Version{Ref: "quux"},
+				},
+				destination: dir,
+				apiclient:   mockMarathoner,
 			},
 			IOOutput{},
 			true,
 		},
+		{
+			"Destroyed app",
+			args{
+				input: InputJSON{
+					Source:  Source{AppID: "gone"},
+					Version: Version{Ref: "foo"},
+				},
+				destination: dir,
+				apiclient:   mockMarathoner,
+			},
+			IOOutput{
+				Version:  Version{Ref: "foo"},
+				Metadata: []Metadata{{Name: "destroyed", Value: "true"}},
+			},
+			false,
+		},
 	}
 	for _, tt := range tests {
-		got, err := In(tt.args.input, tt.args.apiclient)
+		got, err := In(tt.args.input, tt.args.destination, tt.args.apiclient)
 		if (err != nil) != tt.wantErr {
 			t.Errorf("%q. In() error = %v, wantErr %v", tt.name, err, tt.wantErr)
 			continue
@@ -289,6 +448,9 @@ func TestIn(t *testing.T) {
 			t.Errorf("%q. In() = %v, want %v", tt.name, got, tt.want)
 		}
 	}
+	if version, _ := ioutil.ReadFile(filepath.Join(dir, versionFile)); string(version) != "foo" {
+		t.Errorf("In() wrote version %q, want %q", version, "foo")
+	}
 }
 
 func TestCheck(t *testing.T) {
@@ -301,6 +463,13 @@ func TestCheck(t *testing.T) {
 	gomock.InOrder(
 		mockMarathoner.EXPECT().LatestVersions("bar", "").Times(1).Return([]string{"a", "b", "c"}, nil),
 		mockMarathoner.EXPECT().LatestVersions("bar", "").Times(1).Return([]string{}, errors.New("totally whack")),
+		mockMarathoner.EXPECT().LatestVersions("web", "").Times(1).Return(nil, &marathon.APIError{StatusCode: http.StatusNotFound}),
+		mockMarathoner.EXPECT().GetAppJSON("/web-blue", "").Times(1).Return(nil, &marathon.APIError{StatusCode: http.StatusNotFound}),
+		mockMarathoner.EXPECT().GetAppJSON("/web-green", "").Times(1).Return(json.RawMessage(`{"id":"/web-green","labels":{"HAPROXY_DEPLOYMENT_GROUP":"shop","HAPROXY_DEPLOYMENT_COLOUR":"green"}}`), nil),
+		mockMarathoner.EXPECT().LatestVersions("/web-green", "").Times(1).Return([]string{"d"}, nil),
+		mockMarathoner.EXPECT().LatestVersions("api", "").Times(1).Return(nil, &marathon.APIError{StatusCode: http.StatusNotFound}),
+		mockMarathoner.EXPECT().GetAppJSON("/api-blue", "").Times(1).Return(json.RawMessage(`{"id":"/api-blue","labels":{"HAPROXY_DEPLOYMENT_COLOUR":"blue"}}`), nil),
+		mockMarathoner.EXPECT().GetAppJSON("/api-green", "").Times(1).Return(json.RawMessage(`{"id":"/api-green","labels":{"HAPROXY_DEPLOYMENT_COLOUR":"green"}}`), nil),
 	)
 
 	type args struct {
@@ -341,6 +510,30 @@ func TestCheck(t *testing.T) {
 			CheckOutput{},
 			true,
 		},
+		{
+			"Follows the live blue/green color in a custom group",
+			args{
+				input: InputJSON{
+					Source:  Source{AppID: "web"},
+					Version: Version{Ref: ""},
+				},
+				apiclient: mockMarathoner,
+			},
+			CheckOutput{Version{Ref: "d"}},
+			false,
+		},
+		{
+			"Both colors running",
+			args{
+				input: InputJSON{
+					Source:  Source{AppID: "api"},
+					Version: Version{Ref: ""},
+				},
+				apiclient: mockMarathoner,
+			},
+			CheckOutput{},
+			true,
+		},
 	}
 	for _, tt := range tests {
 		got, err := Check(tt.args.input, tt.args.apiclient)
@@ -353,3 +546,51 @@ func TestCheck(t *testing.T) {
 		}
 	}
 }
+
+func TestURIs_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    URIs
+		wantErr bool
+	}{
+		{"Single URI", `"http://foo.bar/"`, URIs{"http://foo.bar/"}, false},
+		{"List of URIs", `["http://foo.bar/","http://baz.bar/"]`, URIs{"http://foo.bar/", "http://baz.bar/"}, false},
+		{"Wrong type", `1`, nil, true},
+	}
+	for _, tt := range tests {
+		var got URIs
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. URIs.UnmarshalJSON() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. URIs.UnmarshalJSON() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAppJSONPaths_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    AppJSONPaths
+		wantErr bool
+	}{
+		{"Single path", `"app.json"`, AppJSONPaths{"app.json"}, false},
+		{"List of paths", `["apps/*.json","web.json"]`, AppJSONPaths{"apps/*.json", "web.json"}, false},
+		{"Wrong type", `1`, nil, true},
+	}
+	for _, tt := range tests {
+		var got AppJSONPaths
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. AppJSONPaths.UnmarshalJSON() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. AppJSONPaths.UnmarshalJSON() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
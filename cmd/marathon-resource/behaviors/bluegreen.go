@@ -0,0 +1,449 @@
+package behaviors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+	gomarathon "github.com/gambol99/go-marathon"
+)
+
+// The ways `strategy` can deploy an app.
+const (
+	strategyRolling   = ""
+	strategyBlueGreen = "blue_green"
+	strategyCanary    = "canary"
+)
+
+const (
+	colorBlue  = "blue"
+	colorGreen = "green"
+
+	// The labels marathon-lb uses to tell the colors of an app apart. They
+	// match the ones set by its zdd.py.
+	labelDeploymentGroup           = "HAPROXY_DEPLOYMENT_GROUP"
+	labelDeploymentColour          = "HAPROXY_DEPLOYMENT_COLOUR"
+	labelDeploymentStartedAt       = "HAPROXY_DEPLOYMENT_STARTED_AT"
+	labelDeploymentTargetInstances = "HAPROXY_DEPLOYMENT_TARGET_INSTANCES"
+	labelDeploymentAltPort         = "HAPROXY_DEPLOYMENT_ALT_PORT"
+	labelServicePort               = "HAPROXY_0_PORT"
+)
+
+// colorApp is a color of an app that is running.
+type colorApp struct {
+	id          string
+	color       string
+	instances   int
+	servicePort int
+}
+
+// deployBlueGreen deploys the app next to the running one under the other
+// color. Once the new color is healthy the old one is scaled down an instance
+// at a time and destroyed. If anything goes wrong the new color is destroyed
+// and the old one is left as it was.
+func deployBlueGreen(
+	input InputJSON,
+	appID string,
+	appJSON []byte,
+	apiclient marathon.Marathoner,
+) (IOOutput, error) {
+	baseID := normalizeID(appID)
+	var app map[string]interface{}
+	if err := decodeDefinition(appJSON, &app); err != nil {
+		return IOOutput{}, err
+	}
+	old, err := liveColor(baseID, deploymentGroup(app, baseID), apiclient)
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	color := colorBlue
+	if old != nil && old.color == colorBlue {
+		color = colorGreen
+	}
+	newID := colorID(baseID, color)
+
+	newJSON, err := colorDefinition(appJSON, baseID, newID, color, old)
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	did, err := updateOnConflict(
+		input,
+		newID,
+		newJSON,
+		apiclient.UpdateApp,
+		apiclient,
+	)
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	if err = checkDeploymentLoop(
+		did.DeploymentID,
+		newID,
+		input.Params,
+		apiclient,
+	); err == nil {
+		err = waitHealthy(newID, did.Version, input.Params, apiclient)
+	}
+	scaledTo := -1
+	if err == nil && old != nil {
+		scaledTo, err = retireColor(old, input.Params, apiclient)
+	}
+	if err != nil {
+		return IOOutput{}, abortBlueGreen(
+			err,
+			newID,
+			old,
+			scaledTo,
+			input.Params,
+			apiclient,
+		)
+	}
+
+	return IOOutput{
+		Version: Version{Ref: did.Version},
+		Metadata: []Metadata{
+			{"app_id", newID},
+			{"color", color},
+		},
+	}, nil
+}
+
+func colorID(baseID, color string) string {
+	return fmt.Sprintf("%s-%s", baseID, color)
+}
+
+// liveColor returns the app of the deployment group that is running, if any.
+// Like zdd.py it finds the colors by their HAPROXY_DEPLOYMENT_GROUP label. An
+// app still running under the plain ID from before the first blue/green
+// deployment is live too. It has no color and is retired like one.
+func liveColor(
+	baseID string,
+	group string,
+	apiclient marathon.Marathoner,
+) (*colorApp, error) {
+	apps, err := apiclient.ListAppsJSON(labelDeploymentGroup + "==" + group)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := apiclient.GetAppJSON(baseID, "")
+	if apiErr, ok := err.(*marathon.APIError); !ok || apiErr.StatusCode != http.StatusNotFound {
+		if err != nil {
+			return nil, err
+		}
+		apps = append(apps, plain)
+	}
+
+	var (
+		live []*colorApp
+		ids  []string
+		seen = map[string]bool{}
+	)
+	for _, appJSON := range apps {
+		var (
+			app    map[string]interface{}
+			status gomarathon.Application
+		)
+		if err := decodeDefinition(appJSON, &app); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(appJSON, &status); err != nil {
+			return nil, err
+		}
+		if seen[status.ID] {
+			continue
+		}
+		seen[status.ID] = true
+
+		labels, _ := app["labels"].(map[string]interface{})
+		color, _ := labels[labelDeploymentColour].(string)
+		live = append(live, &colorApp{
+			id:          status.ID,
+			color:       color,
+			instances:   instances(status),
+			servicePort: servicePort(app),
+		})
+		ids = append(ids, status.ID)
+	}
+
+	switch len(live) {
+	case 0:
+		return nil, nil
+	case 1:
+		return live[0], nil
+	}
+	return nil, fmt.Errorf(
+		"%s are all running in deployment group %s, a previous blue/green deployment did not finish",
+		strings.Join(ids, ", "),
+		group,
+	)
+}
+
+// deploymentGroup returns the HAPROXY_DEPLOYMENT_GROUP of an app. Without the
+// label it's named after the app's ID.
+func deploymentGroup(app map[string]interface{}, baseID string) string {
+	labels, _ := app["labels"].(map[string]interface{})
+	if group, ok := labels[labelDeploymentGroup].(string); ok {
+		return group
+	}
+	return strings.Replace(strings.Trim(baseID, "/"), "/", "_", -1)
+}
+
+// followColor calls get with the app's ID and, when there is no such app,
+// again with the ID of its live blue/green color so `in` and `check` can
+// follow an app deployed with the blue_green strategy. The colors are found
+// by their HAPROXY_DEPLOYMENT_COLOUR label rather than the deployment group,
+// which can be set to anything in the app.
+func followColor(
+	appID string,
+	apiclient marathon.Marathoner,
+	get func(id string) error,
+) error {
+	err := get(appID)
+	if apiErr, ok := err.(*marathon.APIError); !ok || apiErr.StatusCode != http.StatusNotFound {
+		return err
+	}
+
+	baseID := normalizeID(appID)
+	var live []string
+	for _, color := range []string{colorBlue, colorGreen} {
+		id := colorID(baseID, color)
+		appJSON, cerr := apiclient.GetAppJSON(id, "")
+		if apiErr, ok := cerr.(*marathon.APIError); ok && apiErr.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if cerr != nil {
+			return cerr
+		}
+		var app map[string]interface{}
+		if cerr = decodeDefinition(appJSON, &app); cerr != nil {
+			return cerr
+		}
+		labels, _ := app["labels"].(map[string]interface{})
+		if labels[labelDeploymentColour] == color {
+			live = append(live, id)
+		}
+	}
+
+	switch len(live) {
+	case 0:
+		return err
+	case 1:
+		return get(live[0])
+	}
+	return fmt.Errorf(
+		"%s are both running, a previous blue/green deployment did not finish",
+		strings.Join(live, " and "),
+	)
+}
+
+// colorDefinition turns the rendered app into the given color. It sets the
+// marathon-lb labels and, when the running color already has the app's
+// service port, moves the new color to `HAPROXY_DEPLOYMENT_ALT_PORT`.
+func colorDefinition(
+	appJSON []byte,
+	baseID string,
+	id string,
+	color string,
+	old *colorApp,
+) ([]byte, error) {
+	var app map[string]interface{}
+	if err := decodeDefinition(appJSON, &app); err != nil {
+		return nil, err
+	}
+	app["id"] = id
+
+	labels, ok := app["labels"].(map[string]interface{})
+	if !ok {
+		labels = map[string]interface{}{}
+		app["labels"] = labels
+	}
+	labels[labelDeploymentGroup] = deploymentGroup(app, baseID)
+	labels[labelDeploymentColour] = color
+	labels[labelDeploymentStartedAt] = time.Now().UTC().Format(time.RFC3339)
+	labels[labelDeploymentTargetInstances] = "1"
+	if n, ok := app["instances"].(json.Number); ok {
+		labels[labelDeploymentTargetInstances] = n.String()
+	}
+
+	port := servicePort(app)
+	if port == 0 {
+		return encodeDefinition(app)
+	}
+	if _, ok = labels[labelServicePort]; !ok {
+		labels[labelServicePort] = strconv.Itoa(port)
+	}
+	if old == nil || old.servicePort != port {
+		return encodeDefinition(app)
+	}
+
+	altPort, ok := labels[labelDeploymentAltPort].(string)
+	if !ok {
+		return nil, fmt.Errorf(
+			"%s already uses service port %d, set the %s label to the port %s should use",
+			old.id,
+			port,
+			labelDeploymentAltPort,
+			id,
+		)
+	}
+	alt, err := strconv.Atoi(altPort)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s %q: %v", labelDeploymentAltPort, altPort, err)
+	}
+	ports, key := firstServicePort(app)
+	ports[key] = alt
+	return encodeDefinition(app)
+}
+
+// retireColor scales the old color down an instance at a time and destroys
+// it. It returns how many instances the old color was left with.
+func retireColor(
+	old *colorApp,
+	params Params,
+	apiclient marathon.Marathoner,
+) (int, error) {
+	for n := old.instances - 1; n > 0; n-- {
+		did, err := apiclient.ScaleApp(old.id, n)
+		if err != nil {
+			return n + 1, err
+		}
+		if err = waitCleanup(did.DeploymentID, params, apiclient); err != nil {
+			return n, err
+		}
+	}
+
+	did, err := apiclient.DeleteApp(old.id)
+	if err != nil {
+		return 1, err
+	}
+	return 0, waitCleanup(did.DeploymentID, params, apiclient)
+}
+
+// abortBlueGreen destroys the new color and scales the old one back up if it
+// had been scaled down. It returns why the put failed along with anything
+// that went wrong cleaning up.
+func abortBlueGreen(
+	err error,
+	newID string,
+	old *colorApp,
+	scaledTo int,
+	params Params,
+	apiclient marathon.Marathoner,
+) error {
+	var cleanup []string
+
+	if old != nil && scaledTo >= 0 && scaledTo < old.instances {
+		did, serr := apiclient.ScaleApp(old.id, old.instances)
+		if serr == nil {
+			serr = waitCleanup(did.DeploymentID, params, apiclient)
+		}
+		if serr != nil {
+			cleanup = append(cleanup, fmt.Sprintf(
+				"could not scale %s back to %d instances: %v",
+				old.id,
+				old.instances,
+				serr,
+			))
+		}
+	}
+
+	if derr := removeApp(newID, params, apiclient); derr != nil {
+		cleanup = append(cleanup, fmt.Sprintf("could not remove %s: %v", newID, derr))
+	} else {
+		cleanup = append(cleanup, fmt.Sprintf("removed %s", newID))
+	}
+
+	return withCleanup(err, cleanup)
+}
+
+// withCleanup adds what was done to clean up after a failed put to its error.
+// Deployment errors keep their type so their diagnostics are still reported.
+func withCleanup(err error, cleanup []string) error {
+	if dErr, ok := err.(*deploymentError); ok {
+		dErr.err = withCause(dErr.err, "%v; %s", dErr.err, strings.Join(cleanup, "; "))
+		return dErr
+	}
+	return withCause(err, "%v; %s", err, strings.Join(cleanup, "; "))
+}
+
+// removeApp destroys an app we started and waits for it to be gone. An app
+// that is already gone is fine, rolling back its deployment removes it.
+func removeApp(id string, params Params, apiclient marathon.Marathoner) error {
+	did, err := apiclient.DeleteApp(id)
+	if apiErr, ok := err.(*marathon.APIError); ok && apiErr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return waitCleanup(did.DeploymentID, params, apiclient)
+}
+
+// waitCleanup waits for a deployment that is part of switching colors. Unlike
+// checkDeploymentLoop it leaves the deployment be when it times out.
+func waitCleanup(
+	deploymentID string,
+	params Params,
+	apiclient marathon.Marathoner,
+) error {
+	deploying, err := apiclient.WaitDeployment(
+		deploymentID,
+		time.Duration(params.TimeOut)*time.Second,
+	)
+	if err != nil {
+		return err
+	}
+	if deploying {
+		return fmt.Errorf(
+			"Deployment %s did not finish within %d seconds",
+			deploymentID,
+			params.TimeOut,
+		)
+	}
+	return nil
+}
+
+// firstServicePort returns the object holding the app's first service port
+// and the key it's under.
+func firstServicePort(app map[string]interface{}) (map[string]interface{}, string) {
+	if defs, ok := app["portDefinitions"].([]interface{}); ok && len(defs) > 0 {
+		if def, ok := defs[0].(map[string]interface{}); ok {
+			return def, "port"
+		}
+	}
+
+	container, _ := app["container"].(map[string]interface{})
+	mappings, ok := container["portMappings"].([]interface{})
+	if !ok {
+		docker, _ := container["docker"].(map[string]interface{})
+		mappings, _ = docker["portMappings"].([]interface{})
+	}
+	if len(mappings) > 0 {
+		if mapping, ok := mappings[0].(map[string]interface{}); ok {
+			return mapping, "servicePort"
+		}
+	}
+	return nil, ""
+}
+
+// servicePort returns the app's first service port or 0 when it doesn't have
+// one.
+func servicePort(app map[string]interface{}) int {
+	ports, key := firstServicePort(app)
+	n, ok := ports[key].(json.Number)
+	if !ok {
+		return 0
+	}
+	port, err := n.Int64()
+	if err != nil {
+		return 0
+	}
+	return int(port)
+}
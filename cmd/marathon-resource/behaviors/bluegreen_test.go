@@ -0,0 +1,248 @@
+package behaviors
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
+	gomarathon "github.com/gambol99/go-marathon"
+	"github.com/golang/mock/gomock"
+)
+
+func Test_colorDefinition(t *testing.T) {
+	var (
+		plain = `{"id":"foo/bar","instances":3,"labels":{"team":"a"}}`
+		ports = `{"id":"/foo","portDefinitions":[{"port":10000}],"labels":{"HAPROXY_DEPLOYMENT_ALT_PORT":"10001"}}`
+	)
+	tests := []struct {
+		name       string
+		appJSON    string
+		old        *colorApp
+		wantLabels map[string]string
+		wantPort   int
+		wantErr    bool
+	}{
+		{
+			"Labels",
+			plain,
+			nil,
+			map[string]string{
+				"team":                         "a",
+				labelDeploymentGroup:           "foo_bar",
+				labelDeploymentColour:          colorGreen,
+				labelDeploymentTargetInstances: "3",
+			},
+			0,
+			false,
+		},
+		{
+			"Service port is free",
+			ports,
+			&colorApp{id: "/foo-blue", servicePort: 10001},
+			map[string]string{labelServicePort: "10000"},
+			10000,
+			false,
+		},
+		{
+			"Service port is taken",
+			ports,
+			&colorApp{id: "/foo-blue", servicePort: 10000},
+			map[string]string{labelServicePort: "10000"},
+			10001,
+			false,
+		},
+		{
+			"No alternate port",
+			`{"id":"/foo","container":{"docker":{"portMappings":[{"servicePort":10000}]}}}`,
+			&colorApp{id: "/foo-blue", servicePort: 10000},
+			nil,
+			0,
+			true,
+		},
+		{
+			"Bad alternate port",
+			`{"id":"/foo","portDefinitions":[{"port":10000}],"labels":{"HAPROXY_DEPLOYMENT_ALT_PORT":"ten"}}`,
+			&colorApp{id: "/foo-blue", servicePort: 10000},
+			nil,
+			0,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := colorDefinition([]byte(tt.appJSON), "/foo/bar", "/foo-green", colorGreen, tt.old)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. colorDefinition() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var app map[string]interface{}
+		if err = decodeDefinition(got, &app); err != nil {
+			t.Errorf("%q. colorDefinition() = %s: %v", tt.name, got, err)
+			continue
+		}
+		if app["id"] != "/foo-green" {
+			t.Errorf("%q. colorDefinition() id = %v, want /foo-green", tt.name, app["id"])
+		}
+		labels, _ := app["labels"].(map[string]interface{})
+		if labels[labelDeploymentStartedAt] == nil {
+			t.Errorf("%q. colorDefinition() is missing %s", tt.name, labelDeploymentStartedAt)
+		}
+		for k, v := range tt.wantLabels {
+			if labels[k] != v {
+				t.Errorf("%q. colorDefinition() label %s = %v, want %v", tt.name, k, labels[k], v)
+			}
+		}
+		if port := servicePort(app); port != tt.wantPort {
+			t.Errorf("%q. colorDefinition() service port = %d, want %d", tt.name, port, tt.wantPort)
+		}
+	}
+}
+
+func Test_deployBlueGreen(t *testing.T) {
+	var (
+		notFound = &marathon.APIError{StatusCode: http.StatusNotFound}
+		group    = "HAPROXY_DEPLOYMENT_GROUP==foo"
+		blueJSON = json.RawMessage(`{"id":"/foo-blue","instances":3,"portDefinitions":[{"port":10000}],"labels":{"HAPROXY_DEPLOYMENT_COLOUR":"blue"}}`)
+		two      = 2
+		healthy  = gomarathon.Application{Version: "v2", Instances: &two, TasksRunning: 2}
+		deployed = gomarathon.DeploymentID{DeploymentID: "new", Version: "v2"}
+	)
+	tests := []struct {
+		name    string
+		expect  func(m *mocks.MockMarathoner)
+		want    IOOutput
+		wantErr bool
+	}{
+		{
+			"First deployment",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().ListAppsJSON(group).Times(1).Return(nil, nil),
+					m.EXPECT().GetAppJSON("/foo", "").Times(1).Return(nil, notFound),
+					m.EXPECT().UpdateApp("/foo-blue", gomock.Any(), false).Times(1).Return(deployed, nil),
+					m.EXPECT().WaitDeployment("new", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().GetApp("/foo-blue", "").Times(1).Return(healthy, nil),
+				)
+			},
+			IOOutput{
+				Version:  Version{Ref: "v2"},
+				Metadata: []Metadata{{"app_id", "/foo-blue"}, {"color", colorBlue}},
+			},
+			false,
+		},
+		{
+			"Switch to green",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().ListAppsJSON(group).Times(1).Return([]json.RawMessage{blueJSON}, nil),
+					m.EXPECT().GetAppJSON("/foo", "").Times(1).Return(nil, notFound),
+					m.EXPECT().UpdateApp("/foo-green", gomock.Any(), false).Times(1).Return(deployed, nil),
+					m.EXPECT().WaitDeployment("new", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().GetApp("/foo-green", "").Times(1).Return(healthy, nil),
+					m.EXPECT().ScaleApp("/foo-blue", 2).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "down2"}, nil),
+					m.EXPECT().WaitDeployment("down2", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().ScaleApp("/foo-blue", 1).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "down1"}, nil),
+					m.EXPECT().WaitDeployment("down1", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().DeleteApp("/foo-blue").Times(1).Return(gomarathon.DeploymentID{DeploymentID: "delete"}, nil),
+					m.EXPECT().WaitDeployment("delete", gomock.Any()).Times(1).Return(false, nil),
+				)
+			},
+			IOOutput{
+				Version:  Version{Ref: "v2"},
+				Metadata: []Metadata{{"app_id", "/foo-green"}, {"color", colorGreen}},
+			},
+			false,
+		},
+		{
+			"Retire an app deployed without a color",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().ListAppsJSON(group).Times(1).Return(nil, nil),
+					m.EXPECT().GetAppJSON("/foo", "").Times(1).Return(json.RawMessage(`{"id":"/foo","instances":1,"portDefinitions":[{"port":10000}]}`), nil),
+					m.EXPECT().UpdateApp("/foo-blue", gomock.Any(), false).Times(1).Do(func(_ string, appJSON json.RawMessage, _ bool) {
+						var app map[string]interface{}
+						decodeDefinition(appJSON, &app)
+						if servicePort(app) != 10001 {
+							t.Errorf("deployBlueGreen() deployed %s, want it on the alternate port", appJSON)
+						}
+					}).Return(deployed, nil),
+					m.EXPECT().WaitDeployment("new", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().GetApp("/foo-blue", "").Times(1).Return(healthy, nil),
+					m.EXPECT().DeleteApp("/foo").Times(1).Return(gomarathon.DeploymentID{DeploymentID: "delete"}, nil),
+					m.EXPECT().WaitDeployment("delete", gomock.Any()).Times(1).Return(false, nil),
+				)
+			},
+			IOOutput{
+				Version:  Version{Ref: "v2"},
+				Metadata: []Metadata{{"app_id", "/foo-blue"}, {"color", colorBlue}},
+			},
+			false,
+		},
+		{
+			"New color fails",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().ListAppsJSON(group).Times(1).Return([]json.RawMessage{blueJSON}, nil),
+					m.EXPECT().GetAppJSON("/foo", "").Times(1).Return(nil, notFound),
+					m.EXPECT().UpdateApp("/foo-green", gomock.Any(), false).Times(1).Return(deployed, nil),
+					m.EXPECT().WaitDeployment("new", gomock.Any()).Times(1).Return(false, marathon.ErrDeploymentFailed),
+					m.EXPECT().DeleteApp("/foo-green").Times(1).Return(gomarathon.DeploymentID{DeploymentID: "remove"}, nil),
+					m.EXPECT().WaitDeployment("remove", gomock.Any()).Times(1).Return(false, nil),
+				)
+			},
+			IOOutput{},
+			true,
+		},
+		{
+			"Scaling down fails",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().ListAppsJSON(group).Times(1).Return([]json.RawMessage{blueJSON}, nil),
+					m.EXPECT().GetAppJSON("/foo", "").Times(1).Return(nil, notFound),
+					m.EXPECT().UpdateApp("/foo-green", gomock.Any(), false).Times(1).Return(deployed, nil),
+					m.EXPECT().WaitDeployment("new", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().GetApp("/foo-green", "").Times(1).Return(healthy, nil),
+					m.EXPECT().ScaleApp("/foo-blue", 2).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "down2"}, nil),
+					m.EXPECT().WaitDeployment("down2", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().ScaleApp("/foo-blue", 1).Times(1).Return(gomarathon.DeploymentID{}, errors.New("Bad stuff")),
+					m.EXPECT().ScaleApp("/foo-blue", 3).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "restore"}, nil),
+					m.EXPECT().WaitDeployment("restore", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().DeleteApp("/foo-green").Times(1).Return(gomarathon.DeploymentID{}, notFound),
+				)
+			},
+			IOOutput{},
+			true,
+		},
+		{
+			"Both colors running",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().ListAppsJSON(group).Times(1).Return([]json.RawMessage{blueJSON, json.RawMessage(`{"id":"/foo-green"}`)}, nil),
+					m.EXPECT().GetAppJSON("/foo", "").Times(1).Return(nil, notFound),
+				)
+			},
+			IOOutput{},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		ctrl := gomock.NewController(t)
+		mockMarathoner := mocks.NewMockMarathoner(ctrl)
+		tt.expect(mockMarathoner)
+
+		input := InputJSON{Params: Params{TimeOut: 2, Strategy: strategyBlueGreen}}
+		got, err := deployBlueGreen(input, "foo", []byte(`{"id":"foo","instances":2,"portDefinitions":[{"port":10000}],"labels":{"HAPROXY_DEPLOYMENT_ALT_PORT":"10001"}}`), mockMarathoner)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. deployBlueGreen() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. deployBlueGreen() = %v, want %v", tt.name, got, tt.want)
+		}
+		ctrl.Finish()
+	}
+}
@@ -0,0 +1,183 @@
+package behaviors
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+)
+
+// canaryHTTPClient runs the `canary_check_url` check.
+var canaryHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// deployCanary deploys the app as a separate canary app with a few instances
+// and watches it for `soak_period` seconds. If it stays healthy and passes
+// the `canary_check_url` check the app is updated and the canary removed.
+// Otherwise the canary is destroyed and the app is left as it was.
+func deployCanary(
+	input InputJSON,
+	appID string,
+	appJSON []byte,
+	apiclient marathon.Marathoner,
+) (IOOutput, error) {
+	canaryID := normalizeID(appID) + "-canary"
+	canaryJSON, err := canaryDefinition(
+		appJSON,
+		canaryID,
+		input.Params.CanaryInstances,
+	)
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	did, err := updateOnConflict(
+		input,
+		canaryID,
+		canaryJSON,
+		apiclient.UpdateApp,
+		apiclient,
+	)
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	if err = checkDeploymentLoop(
+		did.DeploymentID,
+		canaryID,
+		input.Params,
+		apiclient,
+	); err == nil {
+		if err = waitHealthy(
+			canaryID,
+			did.Version,
+			input.Params,
+			apiclient,
+		); err == nil {
+			err = soakCanary(canaryID, did.Version, input.Params, apiclient)
+		}
+	}
+	if err != nil {
+		return IOOutput{}, abortCanary(err, canaryID, input.Params, apiclient)
+	}
+
+	output, err := deployApp(input, appID, appJSON, apiclient)
+	if err != nil {
+		return IOOutput{}, abortCanary(err, canaryID, input.Params, apiclient)
+	}
+	if err = removeApp(canaryID, input.Params, apiclient); err != nil {
+		return IOOutput{}, withCause(
+			err,
+			"Deployed %s but could not remove %s: %v",
+			normalizeID(appID),
+			canaryID,
+			err,
+		)
+	}
+	return output, nil
+}
+
+// canaryDefinition turns the rendered app into its canary. The canary gets
+// its own service ports since Marathon doesn't let two apps share one.
+func canaryDefinition(appJSON []byte, id string, instances int) ([]byte, error) {
+	var app map[string]interface{}
+	if err := decodeDefinition(appJSON, &app); err != nil {
+		return nil, err
+	}
+	app["id"] = id
+	if instances < 1 {
+		instances = 1
+	}
+	app["instances"] = instances
+
+	if defs, ok := app["portDefinitions"].([]interface{}); ok {
+		zeroPorts(defs, "port")
+	}
+	if container, ok := app["container"].(map[string]interface{}); ok {
+		if mappings, ok := container["portMappings"].([]interface{}); ok {
+			zeroPorts(mappings, "servicePort")
+		}
+		if docker, ok := container["docker"].(map[string]interface{}); ok {
+			if mappings, ok := docker["portMappings"].([]interface{}); ok {
+				zeroPorts(mappings, "servicePort")
+			}
+		}
+	}
+	return encodeDefinition(app)
+}
+
+// zeroPorts lets Marathon pick the ports.
+func zeroPorts(ports []interface{}, key string) {
+	for _, p := range ports {
+		if port, ok := p.(map[string]interface{}); ok {
+			if _, ok = port[key]; ok {
+				port[key] = 0
+			}
+		}
+	}
+}
+
+// soakCanary checks the canary until `soak_period` seconds have passed. It
+// fails as soon as the canary is unhealthy or the check fails.
+func soakCanary(
+	id string,
+	version string,
+	params Params,
+	apiclient marathon.Marathoner,
+) error {
+	deadline := time.Now().Add(time.Duration(params.SoakPeriod) * time.Second)
+	for {
+		app, err := apiclient.GetApp(id, "")
+		if err != nil {
+			return err
+		}
+		if !isHealthy(app, version) {
+			return fmt.Errorf(
+				"Canary %s became unhealthy: %d of %d instances healthy, %d unhealthy, failing tasks: %s",
+				id,
+				app.TasksHealthy,
+				instances(app),
+				app.TasksUnhealthy,
+				strings.Join(failingTasks(app, version), ", "),
+			)
+		}
+		if params.CanaryCheckURL != "" {
+			if err = canaryCheck(params.CanaryCheckURL); err != nil {
+				return fmt.Errorf("Canary %s failed its check: %v", id, err)
+			}
+		}
+		if !time.Now().Before(deadline) {
+			return nil
+		}
+		time.Sleep(healthPollInterval)
+	}
+}
+
+// canaryCheck passes when the URL responds with a 2xx status.
+func canaryCheck(url string) error {
+	res, err := canaryHTTPClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("GET %s responded with %s", url, res.Status)
+	}
+	return nil
+}
+
+// abortCanary destroys the canary and returns why the put failed.
+func abortCanary(
+	err error,
+	canaryID string,
+	params Params,
+	apiclient marathon.Marathoner,
+) error {
+	if rerr := removeApp(canaryID, params, apiclient); rerr != nil {
+		return withCleanup(err, []string{
+			fmt.Sprintf("could not remove %s: %v", canaryID, rerr),
+		})
+	}
+	return withCleanup(err, []string{fmt.Sprintf("removed %s", canaryID)})
+}
@@ -0,0 +1,196 @@
+package behaviors
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
+	gomarathon "github.com/gambol99/go-marathon"
+	"github.com/golang/mock/gomock"
+)
+
+func Test_canaryDefinition(t *testing.T) {
+	tests := []struct {
+		name      string
+		appJSON   string
+		instances int
+		want      string
+		wantErr   bool
+	}{
+		{
+			"Port definitions",
+			`{"id":"/foo","instances":5,"portDefinitions":[{"port":10000,"name":"http"}]}`,
+			2,
+			`{"id":"/foo-canary","instances":2,"portDefinitions":[{"name":"http","port":0}]}`,
+			false,
+		},
+		{
+			"Docker port mappings",
+			`{"id":"/foo","container":{"docker":{"portMappings":[{"containerPort":80,"servicePort":10000}]}}}`,
+			0,
+			`{"container":{"docker":{"portMappings":[{"containerPort":80,"servicePort":0}]}},"id":"/foo-canary","instances":1}`,
+			false,
+		},
+		{
+			"Container port mappings",
+			`{"id":"/foo","container":{"portMappings":[{"containerPort":80,"servicePort":10000}]}}`,
+			1,
+			`{"container":{"portMappings":[{"containerPort":80,"servicePort":0}]},"id":"/foo-canary","instances":1}`,
+			false,
+		},
+		{
+			"Keeps HTML characters",
+			`{"id":"/foo","cmd":"a && b > c"}`,
+			1,
+			`{"cmd":"a && b > c","id":"/foo-canary","instances":1}`,
+			false,
+		},
+		{"Bad JSON", `{]`, 1, "", true},
+	}
+	for _, tt := range tests {
+		got, err := canaryDefinition([]byte(tt.appJSON), "/foo-canary", tt.instances)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. canaryDefinition() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%q. canaryDefinition() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_deployCanary(t *testing.T) {
+	var (
+		two      = 2
+		canary   = gomarathon.DeploymentID{DeploymentID: "canary", Version: "v2"}
+		deployed = gomarathon.DeploymentID{DeploymentID: "app", Version: "v3"}
+		healthy  = gomarathon.Application{Version: "v2", Instances: &two, TasksRunning: 2}
+		sick     = gomarathon.Application{
+			Version:        "v2",
+			Instances:      &two,
+			TasksRunning:   2,
+			TasksUnhealthy: 1,
+			HealthChecks:   &[]gomarathon.HealthCheck{{Protocol: "HTTP"}},
+		}
+		removed = gomarathon.DeploymentID{DeploymentID: "remove"}
+	)
+	check := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer check.Close()
+
+	startCanary := func(m *mocks.MockMarathoner) []*gomock.Call {
+		return []*gomock.Call{
+			m.EXPECT().UpdateApp("/foo-canary", gomock.Any(), false).Times(1).Return(canary, nil),
+			m.EXPECT().WaitDeployment("canary", gomock.Any()).Times(1).Return(false, nil),
+			m.EXPECT().GetApp("/foo-canary", "").Times(1).Return(healthy, nil),
+		}
+	}
+	tests := []struct {
+		name     string
+		checkURL string
+		expect   func(m *mocks.MockMarathoner)
+		want     IOOutput
+		wantErr  bool
+	}{
+		{
+			"Promoted",
+			check.URL + "/ok",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(append(
+					startCanary(m),
+					m.EXPECT().GetApp("/foo-canary", "").Times(1).Return(healthy, nil),
+					m.EXPECT().UpdateApp("foo", json.RawMessage(`{"id":"foo","instances":2}`), false).Times(1).Return(deployed, nil),
+					m.EXPECT().WaitDeployment("app", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().LatestVersions("foo", "").Times(1).Return([]string{"v3"}, nil),
+					m.EXPECT().DeleteApp("/foo-canary").Times(1).Return(removed, nil),
+					m.EXPECT().WaitDeployment("remove", gomock.Any()).Times(1).Return(false, nil),
+				)...)
+			},
+			IOOutput{Version: Version{Ref: "v3"}},
+			false,
+		},
+		{
+			"Canary becomes unhealthy",
+			"",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(append(
+					startCanary(m),
+					m.EXPECT().GetApp("/foo-canary", "").Times(1).Return(sick, nil),
+					m.EXPECT().DeleteApp("/foo-canary").Times(1).Return(removed, nil),
+					m.EXPECT().WaitDeployment("remove", gomock.Any()).Times(1).Return(false, nil),
+				)...)
+			},
+			IOOutput{},
+			true,
+		},
+		{
+			"Check fails",
+			check.URL + "/broken",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(append(
+					startCanary(m),
+					m.EXPECT().GetApp("/foo-canary", "").Times(1).Return(healthy, nil),
+					m.EXPECT().DeleteApp("/foo-canary").Times(1).Return(removed, nil),
+					m.EXPECT().WaitDeployment("remove", gomock.Any()).Times(1).Return(false, nil),
+				)...)
+			},
+			IOOutput{},
+			true,
+		},
+		{
+			"Canary fails to deploy",
+			"",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().UpdateApp("/foo-canary", gomock.Any(), false).Times(1).Return(canary, nil),
+					m.EXPECT().WaitDeployment("canary", gomock.Any()).Times(1).Return(false, marathon.ErrDeploymentFailed),
+					m.EXPECT().DeleteApp("/foo-canary").Times(1).Return(gomarathon.DeploymentID{}, &marathon.APIError{StatusCode: http.StatusNotFound}),
+				)
+			},
+			IOOutput{},
+			true,
+		},
+		{
+			"Promotion fails",
+			"",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(append(
+					startCanary(m),
+					m.EXPECT().GetApp("/foo-canary", "").Times(1).Return(healthy, nil),
+					m.EXPECT().UpdateApp("foo", gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{}, errors.New("Bad stuff")),
+					m.EXPECT().DeleteApp("/foo-canary").Times(1).Return(removed, nil),
+					m.EXPECT().WaitDeployment("remove", gomock.Any()).Times(1).Return(false, nil),
+				)...)
+			},
+			IOOutput{},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		ctrl := gomock.NewController(t)
+		mockMarathoner := mocks.NewMockMarathoner(ctrl)
+		tt.expect(mockMarathoner)
+
+		input := InputJSON{Params: Params{
+			TimeOut:         2,
+			Strategy:        strategyCanary,
+			CanaryInstances: 2,
+			CanaryCheckURL:  tt.checkURL,
+		}}
+		got, err := deployCanary(input, "foo", []byte(`{"id":"foo","instances":2}`), mockMarathoner)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. deployCanary() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. deployCanary() = %v, want %v", tt.name, got, tt.want)
+		}
+		ctrl.Finish()
+	}
+}
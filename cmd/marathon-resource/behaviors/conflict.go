@@ -0,0 +1,101 @@
+package behaviors
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+	gomarathon "github.com/gambol99/go-marathon"
+)
+
+// The ways `on_conflict` can handle a definition locked by another
+// deployment.
+const (
+	conflictFail   = ""
+	conflictWait   = "wait"
+	conflictCancel = "cancel"
+	conflictForce  = "force"
+)
+
+// updateOnConflict sends a definition to Marathon. When another deployment
+// holds the lock on it the conflict is handled as set by `on_conflict` and
+// the update is sent once more.
+func updateOnConflict(
+	input InputJSON,
+	id string,
+	definitionJSON []byte,
+	update updateFunc,
+	apiclient marathon.Marathoner,
+) (gomarathon.DeploymentID, error) {
+	did, err := update(id, definitionJSON, false)
+	blocking := blockingDeployments(err)
+	if len(blocking) == 0 {
+		return did, err
+	}
+
+	timeOut := time.Duration(input.Params.TimeOut)
+	switch input.Params.OnConflict {
+	case conflictWait:
+		if err = waitDeployments(blocking, timeOut, apiclient); err != nil {
+			return gomarathon.DeploymentID{}, err
+		}
+		return update(id, definitionJSON, false)
+	case conflictCancel:
+		for _, deploymentID := range blocking {
+			rollback, err := apiclient.DeleteDeployment(deploymentID, false)
+			if err != nil {
+				return gomarathon.DeploymentID{}, err
+			}
+			// The rollback holds the lock until it's done.
+			if err = waitDeployments(
+				[]string{rollback.DeploymentID},
+				timeOut,
+				apiclient,
+			); err != nil {
+				return gomarathon.DeploymentID{}, err
+			}
+		}
+		return update(id, definitionJSON, false)
+	case conflictForce:
+		return update(id, definitionJSON, true)
+	}
+	return did, err
+}
+
+// blockingDeployments returns the deployments that kept an update from being
+// accepted.
+func blockingDeployments(err error) []string {
+	apiErr, ok := err.(*marathon.APIError)
+	if !ok || apiErr.StatusCode != http.StatusConflict {
+		return nil
+	}
+	return apiErr.Deployments
+}
+
+// waitDeployments waits for deployments someone else started. It doesn't
+// matter to us whether they succeed, only that they are done.
+func waitDeployments(
+	deploymentIDs []string,
+	timeOut time.Duration,
+	apiclient marathon.Marathoner,
+) error {
+	for _, deploymentID := range deploymentIDs {
+		if deploymentID == "" {
+			continue
+		}
+		deploying, err := apiclient.WaitDeployment(deploymentID, timeOut*time.Second)
+		if err != nil && err != marathon.ErrDeploymentFailed {
+			return err
+		}
+		if deploying {
+			return fmt.Errorf(
+				"Blocking deployments %s did not finish within %d seconds",
+				strings.Join(deploymentIDs, ", "),
+				timeOut,
+			)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package behaviors
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
+	gomarathon "github.com/gambol99/go-marathon"
+	"github.com/golang/mock/gomock"
+)
+
+func Test_updateOnConflict(t *testing.T) {
+	var (
+		locked = &marathon.APIError{
+			StatusCode:  http.StatusConflict,
+			Method:      http.MethodPut,
+			Path:        "/v2/apps/foo",
+			Deployments: []string{"blocker"},
+		}
+		deployed = gomarathon.DeploymentID{DeploymentID: "ours", Version: "bar"}
+	)
+	tests := []struct {
+		name       string
+		onConflict string
+		expect     func(m *mocks.MockMarathoner)
+		want       gomarathon.DeploymentID
+		wantErr    bool
+	}{
+		{
+			"No conflict",
+			conflictWait,
+			func(m *mocks.MockMarathoner) {
+				m.EXPECT().UpdateApp("/foo", gomock.Any(), false).Times(1).Return(deployed, nil)
+			},
+			deployed,
+			false,
+		},
+		{
+			"Fails by default",
+			conflictFail,
+			func(m *mocks.MockMarathoner) {
+				m.EXPECT().UpdateApp("/foo", gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{}, locked)
+			},
+			gomarathon.DeploymentID{},
+			true,
+		},
+		{
+			"Other errors aren't handled",
+			conflictForce,
+			func(m *mocks.MockMarathoner) {
+				m.EXPECT().UpdateApp("/foo", gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{}, errors.New("no way"))
+			},
+			gomarathon.DeploymentID{},
+			true,
+		},
+		{
+			"Waits",
+			conflictWait,
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().UpdateApp("/foo", gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{}, locked),
+					m.EXPECT().WaitDeployment("blocker", 2*time.Second).Times(1).Return(false, marathon.ErrDeploymentFailed),
+					m.EXPECT().UpdateApp("/foo", gomock.Any(), false).Times(1).Return(deployed, nil),
+				)
+			},
+			deployed,
+			false,
+		},
+		{
+			"Waits too long",
+			conflictWait,
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().UpdateApp("/foo", gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{}, locked),
+					m.EXPECT().WaitDeployment("blocker", 2*time.Second).Times(1).Return(true, nil),
+				)
+			},
+			gomarathon.DeploymentID{},
+			true,
+		},
+		{
+			"Cancels",
+			conflictCancel,
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().UpdateApp("/foo", gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{}, locked),
+					m.EXPECT().DeleteDeployment("blocker", false).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "rollback"}, nil),
+					m.EXPECT().WaitDeployment("rollback", 2*time.Second).Times(1).Return(false, nil),
+					m.EXPECT().UpdateApp("/foo", gomock.Any(), false).Times(1).Return(deployed, nil),
+				)
+			},
+			deployed,
+			false,
+		},
+		{
+			"Cancel fails",
+			conflictCancel,
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().UpdateApp("/foo", gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{}, locked),
+					m.EXPECT().DeleteDeployment("blocker", false).Times(1).Return(gomarathon.DeploymentID{}, errors.New("no way")),
+				)
+			},
+			gomarathon.DeploymentID{},
+			true,
+		},
+		{
+			"Forces",
+			conflictForce,
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().UpdateApp("/foo", gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{}, locked),
+					m.EXPECT().UpdateApp("/foo", gomock.Any(), true).Times(1).Return(deployed, nil),
+				)
+			},
+			deployed,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		ctrl := gomock.NewController(t)
+		mockMarathoner := mocks.NewMockMarathoner(ctrl)
+		tt.expect(mockMarathoner)
+
+		got, err := updateOnConflict(
+			InputJSON{Params: Params{TimeOut: 2, OnConflict: tt.onConflict}},
+			"/foo",
+			[]byte(`{"id":"/foo"}`),
+			mockMarathoner.UpdateApp,
+			mockMarathoner,
+		)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. updateOnConflict() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. updateOnConflict() = %v, want %v", tt.name, got, tt.want)
+		}
+		ctrl.Finish()
+	}
+}
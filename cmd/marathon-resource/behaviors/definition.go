@@ -0,0 +1,133 @@
+package behaviors
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+	gomarathon "github.com/gambol99/go-marathon"
+)
+
+const (
+	kindApp   = "app"
+	kindGroup = "group"
+	kindPod   = "pod"
+)
+
+type (
+	// definition holds the fields of a rendered definition needed to deploy
+	// it.
+	definition struct {
+		ID         string          `json:"id"`
+		Apps       json.RawMessage `json:"apps"`
+		Groups     json.RawMessage `json:"groups"`
+		Containers json.RawMessage `json:"containers"`
+	}
+
+	updateFunc         func(id string, definition json.RawMessage, force bool) (gomarathon.DeploymentID, error)
+	latestVersionsFunc func(id, version string) ([]string, error)
+	getJSONFunc        func(id, version string) (json.RawMessage, error)
+	// metadataFunc returns the version and metadata of a fetched definition.
+	metadataFunc func(definition []byte) (string, []Metadata, error)
+)
+
+// kind returns the kind set in the source or, when it isn't set, the kind
+// the definition looks like.
+func (d definition) kind(sourceKind string) string {
+	switch {
+	case sourceKind != "":
+		return sourceKind
+	case d.Containers != nil:
+		return kindPod
+	case d.Apps != nil || d.Groups != nil:
+		return kindGroup
+	default:
+		return kindApp
+	}
+}
+
+// deployDefinition deploys a group or pod and returns its latest version.
+func deployDefinition(
+	input InputJSON,
+	kind string,
+	id string,
+	definitionJSON []byte,
+	update updateFunc,
+	latestVersions latestVersionsFunc,
+	apiclient marathon.Marathoner,
+) (IOOutput, error) {
+	did, err := updateOnConflict(input, id, definitionJSON, update, apiclient)
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	if err = checkDeploymentLoop(
+		did.DeploymentID,
+		id,
+		input.Params,
+		apiclient,
+	); err != nil {
+		if derr, ok := err.(*deploymentError); ok {
+			derr.kind = kind
+		}
+		return IOOutput{}, err
+	}
+
+	versions, err := latestVersions(id, did.Version)
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	return IOOutput{Version: Version{Ref: versions[len(versions)-1]}}, nil
+}
+
+// fetchDefinition writes a group or pod at the requested version to the
+// destination.
+func fetchDefinition(
+	input InputJSON,
+	destination string,
+	getJSON getJSONFunc,
+	file string,
+	metadata metadataFunc,
+) (IOOutput, error) {
+	definitionJSON, err := getJSON(input.Source.AppID, input.Version.Ref)
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	version, md, err := metadata(definitionJSON)
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	output := IOOutput{Version: Version{Ref: version}, Metadata: md}
+	if err = writeInFiles(
+		destination,
+		map[string][]byte{file: definitionJSON},
+		output,
+	); err != nil {
+		return IOOutput{}, err
+	}
+
+	return output, nil
+}
+
+// decodeDefinition decodes an app, or any part of one, into v keeping numbers
+// as they were written.
+func decodeDefinition(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// encodeDefinition encodes a decoded app without escaping HTML characters so
+// its values are sent as they were written.
+func encodeDefinition(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
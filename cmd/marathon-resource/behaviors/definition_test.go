@@ -0,0 +1,232 @@
+package behaviors
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
+	gomarathon "github.com/gambol99/go-marathon"
+	"github.com/golang/mock/gomock"
+)
+
+func Test_definition_kind(t *testing.T) {
+	tests := []struct {
+		name       string
+		def        string
+		sourceKind string
+		want       string
+	}{
+		{"App", `{"id":"/foo","cmd":"sleep 10"}`, "", kindApp},
+		{"Group with apps", `{"id":"/foo","apps":[]}`, "", kindGroup},
+		{"Group with groups", `{"id":"/foo","groups":[]}`, "", kindGroup},
+		{"Pod", `{"id":"/foo","containers":[]}`, "", kindPod},
+		{"Kind set in source", `{"id":"/foo"}`, kindGroup, kindGroup},
+		{"Pod kind set in source", `{"id":"/foo"}`, kindPod, kindPod},
+	}
+	for _, tt := range tests {
+		var d definition
+		if err := json.Unmarshal([]byte(tt.def), &d); err != nil {
+			t.Fatal(err)
+		}
+		if got := d.kind(tt.sourceKind); got != tt.want {
+			t.Errorf("%q. definition.kind() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOut_group(t *testing.T) {
+	var (
+		ctrl           = gomock.NewController(t)
+		mockMarathoner = mocks.NewMockMarathoner(ctrl)
+		input          = InputJSON{Params: Params{AppJSON: AppJSONPaths{"group.json"}, TimeOut: 2}}
+	)
+	defer ctrl.Finish()
+
+	groupJSON, err := ioutil.ReadFile("../fixtures/group.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gomock.InOrder(
+		mockMarathoner.EXPECT().UpdateGroup("/product", json.RawMessage(groupJSON), false).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "foo", Version: "bar"}, nil),
+		mockMarathoner.EXPECT().WaitDeployment("foo", 2*time.Second).Times(1).Return(false, nil),
+		mockMarathoner.EXPECT().LatestGroupVersions("/product", "bar").Times(1).Return([]string{"bar"}, nil),
+		mockMarathoner.EXPECT().UpdateGroup("/product", gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{}, errors.New("locked")),
+	)
+
+	got, err := Out(input, "../fixtures", mockMarathoner)
+	if err != nil {
+		t.Errorf("Out() error = %v", err)
+	}
+	if want := (IOOutput{Version: Version{Ref: "bar"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Out() = %v, want %v", got, want)
+	}
+	if _, err = Out(input, "../fixtures", mockMarathoner); err == nil {
+		t.Error("Out() expected an error from UpdateGroup")
+	}
+}
+
+func TestIn_group(t *testing.T) {
+	var (
+		ctrl           = gomock.NewController(t)
+		mockMarathoner = mocks.NewMockMarathoner(ctrl)
+		input          = InputJSON{
+			Source:  Source{AppID: "/product", Kind: kindGroup},
+			Version: Version{Ref: "bar"},
+		}
+	)
+	defer ctrl.Finish()
+
+	dir, err := ioutil.TempDir("", "marathon-resource-in-group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gomock.InOrder(
+		mockMarathoner.EXPECT().GetGroupJSON("/product", "bar").Times(1).Return(json.RawMessage(`{"id":"/product","version":"bar","apps":[{"id":"/product/web"}]}`), nil),
+		mockMarathoner.EXPECT().GetGroupJSON("/product", "bar").Times(1).Return(nil, errors.New("not found")),
+	)
+
+	got, err := In(input, dir, mockMarathoner)
+	if err != nil {
+		t.Errorf("In() error = %v", err)
+	}
+	want := IOOutput{
+		Version: Version{Ref: "bar"},
+		Metadata: []Metadata{
+			{"id", "/product"},
+			{"version", "bar"},
+			{"apps", "1"},
+			{"groups", "0"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("In() = %v, want %v", got, want)
+	}
+	if _, err = os.Stat(filepath.Join(dir, groupFile)); err != nil {
+		t.Errorf("In() did not write %s: %v", groupFile, err)
+	}
+	if _, err = In(input, dir, mockMarathoner); err == nil {
+		t.Error("In() expected an error from GetGroupJSON")
+	}
+}
+
+func TestCheck_group(t *testing.T) {
+	var (
+		ctrl           = gomock.NewController(t)
+		mockMarathoner = mocks.NewMockMarathoner(ctrl)
+	)
+	defer ctrl.Finish()
+
+	mockMarathoner.EXPECT().LatestGroupVersions("/product", "a").Times(1).Return([]string{"a", "b"}, nil)
+
+	got, err := Check(
+		InputJSON{Source: Source{AppID: "/product", Kind: kindGroup}, Version: Version{Ref: "a"}},
+		mockMarathoner,
+	)
+	if err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	if want := (CheckOutput{{Ref: "a"}, {Ref: "b"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() = %v, want %v", got, want)
+	}
+}
+
+func TestOut_pod(t *testing.T) {
+	var (
+		ctrl           = gomock.NewController(t)
+		mockMarathoner = mocks.NewMockMarathoner(ctrl)
+		input          = InputJSON{Params: Params{AppJSON: AppJSONPaths{"pod.json"}, TimeOut: 2}}
+	)
+	defer ctrl.Finish()
+	defer allowDiagnostics(t, mockMarathoner)()
+
+	podJSON, err := ioutil.ReadFile("../fixtures/pod.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gomock.InOrder(
+		mockMarathoner.EXPECT().UpdatePod("/web-with-sidecar", json.RawMessage(podJSON), false).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "foo", Version: "bar"}, nil),
+		mockMarathoner.EXPECT().WaitDeployment("foo", 2*time.Second).Times(1).Return(true, nil),
+		mockMarathoner.EXPECT().DeleteDeployment("foo", false).Times(1).Return(gomarathon.DeploymentID{}, nil),
+	)
+
+	if _, err = Out(input, "../fixtures", mockMarathoner); err == nil {
+		t.Error("Out() expected an error from the timed out deployment")
+	}
+}
+
+func TestIn_pod(t *testing.T) {
+	var (
+		ctrl           = gomock.NewController(t)
+		mockMarathoner = mocks.NewMockMarathoner(ctrl)
+		input          = InputJSON{
+			Source:  Source{AppID: "/web-with-sidecar", Kind: kindPod},
+			Version: Version{Ref: "bar"},
+		}
+	)
+	defer ctrl.Finish()
+
+	dir, err := ioutil.TempDir("", "marathon-resource-in-pod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gomock.InOrder(
+		mockMarathoner.EXPECT().GetPodJSON("/web-with-sidecar", "bar").Times(1).Return(json.RawMessage(`{"id":"/web-with-sidecar","version":"bar","scaling":{"instances":2},"containers":[{},{}]}`), nil),
+		mockMarathoner.EXPECT().GetPodJSON("/web-with-sidecar", "bar").Times(1).Return(json.RawMessage(`{]`), nil),
+	)
+
+	got, err := In(input, dir, mockMarathoner)
+	if err != nil {
+		t.Errorf("In() error = %v", err)
+	}
+	want := IOOutput{
+		Version: Version{Ref: "bar"},
+		Metadata: []Metadata{
+			{"id", "/web-with-sidecar"},
+			{"version", "bar"},
+			{"containers", "2"},
+			{"instances", "2"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("In() = %v, want %v", got, want)
+	}
+	if _, err = os.Stat(filepath.Join(dir, podFile)); err != nil {
+		t.Errorf("In() did not write %s: %v", podFile, err)
+	}
+	if _, err = In(input, dir, mockMarathoner); err == nil {
+		t.Error("In() expected an error from the bad pod JSON")
+	}
+}
+
+func TestCheck_pod(t *testing.T) {
+	var (
+		ctrl           = gomock.NewController(t)
+		mockMarathoner = mocks.NewMockMarathoner(ctrl)
+	)
+	defer ctrl.Finish()
+
+	mockMarathoner.EXPECT().LatestPodVersions("/web-with-sidecar", "").Times(1).Return([]string{"a"}, nil)
+
+	got, err := Check(
+		InputJSON{Source: Source{AppID: "/web-with-sidecar", Kind: kindPod}},
+		mockMarathoner,
+	)
+	if err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	if want := (CheckOutput{{Ref: "a"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,331 @@
+package behaviors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+	gomarathon "github.com/gambol99/go-marathon"
+)
+
+type (
+	// deploymentError is returned when a deployment fails or times out. It
+	// keeps what is needed to find out why.
+	deploymentError struct {
+		deploymentID string
+		// id is the app, group or pod that was deployed.
+		id string
+		// kind is what id is. It's empty for apps.
+		kind string
+		// deployment is the deployment as it was when it timed out. Failed
+		// deployments are gone by the time we hear about them.
+		deployment *gomarathon.Deployment
+		err        error
+	}
+
+	// diagnostics is what we could find out about a failed deployment.
+	diagnostics struct {
+		DeploymentID string                 `json:"deploymentId"`
+		Error        string                 `json:"error"`
+		Deployment   *deploymentDiagnostics `json:"deployment,omitempty"`
+		Apps         []appDiagnostics       `json:"apps"`
+		// Errors lists what couldn't be collected.
+		Errors []string `json:"errors,omitempty"`
+	}
+
+	deploymentDiagnostics struct {
+		CurrentStep    int                            `json:"currentStep"`
+		TotalSteps     int                            `json:"totalSteps"`
+		Steps          [][]*gomarathon.DeploymentStep `json:"steps"`
+		CurrentActions []*gomarathon.DeploymentStep   `json:"currentActions"`
+	}
+
+	// appDiagnostics is what we could find out about an app or a pod.
+	appDiagnostics struct {
+		ID              string                      `json:"id"`
+		Kind            string                      `json:"kind"`
+		LastTaskFailure *gomarathon.LastTaskFailure `json:"lastTaskFailure,omitempty"`
+		Queue           *queueItem                  `json:"queue,omitempty"`
+		Tasks           []task                      `json:"tasks"`
+	}
+
+	// queueItem is an app or pod waiting in the Marathon launch queue.
+	queueItem struct {
+		Count                  int              `json:"count"`
+		Delay                  gomarathon.Delay `json:"delay"`
+		Since                  string           `json:"since,omitempty"`
+		App                    *queuedRunSpec   `json:"app,omitempty"`
+		Pod                    *queuedRunSpec   `json:"pod,omitempty"`
+		ProcessedOffersSummary *struct {
+			ProcessedOffersCount    int `json:"processedOffersCount"`
+			UnusedOffersCount       int `json:"unusedOffersCount"`
+			RejectSummaryLastOffers []struct {
+				Reason    string `json:"reason"`
+				Declined  int    `json:"declined"`
+				Processed int    `json:"processed"`
+			} `json:"rejectSummaryLastOffers,omitempty"`
+		} `json:"processedOffersSummary,omitempty"`
+	}
+
+	queuedRunSpec struct {
+		ID string `json:"id"`
+	}
+
+	// task holds the parts of a task shown in the diagnostics. Unlike
+	// go-marathon's it keeps the Mesos state.
+	task struct {
+		ID      string `json:"id"`
+		Host    string `json:"host"`
+		State   string `json:"state"`
+		Version string `json:"version"`
+	}
+
+	// podStatus holds the parts of a pod's status shown in the diagnostics.
+	podStatus struct {
+		Instances []struct {
+			ID            string `json:"id"`
+			Status        string `json:"status"`
+			AgentHostname string `json:"agentHostname"`
+			SpecReference string `json:"specReference"`
+		} `json:"instances"`
+	}
+)
+
+func (e *deploymentError) Error() string {
+	return e.err.Error()
+}
+
+// Cause returns why the deployment failed.
+func (e *deploymentError) Cause() error {
+	return e.err
+}
+
+func (q queueItem) id() string {
+	switch {
+	case q.App != nil:
+		return q.App.ID
+	case q.Pod != nil:
+		return q.Pod.ID
+	}
+	return ""
+}
+
+// reportDiagnostics prints what went wrong with a deployment and writes it as
+// JSON to a report file. It returns the file's path, or "" when it couldn't
+// be written.
+func reportDiagnostics(
+	derr *deploymentError,
+	apiclient marathon.Marathoner,
+) string {
+	d := gatherDiagnostics(derr, apiclient)
+	d.print(buildLog)
+
+	diagnosticsJSON, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		fmt.Fprintf(buildLog, "Unable to write the diagnostics: %v\n", err)
+		return ""
+	}
+	path, err := writeReport(diagnosticsFile, diagnosticsJSON)
+	if err != nil {
+		fmt.Fprintf(buildLog, "Unable to write the diagnostics: %v\n", err)
+		return ""
+	}
+	fmt.Fprintf(buildLog, "Diagnostics written to %s\n", path)
+	return path
+}
+
+func gatherDiagnostics(
+	derr *deploymentError,
+	apiclient marathon.Marathoner,
+) diagnostics {
+	d := diagnostics{DeploymentID: derr.deploymentID, Error: derr.Error()}
+
+	// Marathon lists the apps a deployment affects but not its pods, and a
+	// group is only diagnosed through its apps.
+	var appIDs, podIDs []string
+	switch derr.kind {
+	case kindPod:
+		podIDs = []string{derr.id}
+	case kindGroup:
+	default:
+		appIDs = []string{derr.id}
+	}
+	if dep := derr.deployment; dep != nil {
+		d.Deployment = &deploymentDiagnostics{
+			CurrentStep:    dep.CurrentStep,
+			TotalSteps:     dep.TotalSteps,
+			Steps:          dep.Steps,
+			CurrentActions: dep.CurrentActions,
+		}
+		if len(dep.AffectedApps) > 0 {
+			appIDs = dep.AffectedApps
+		}
+	}
+	if derr.kind == kindGroup && len(appIDs) == 0 {
+		ids, err := groupAppIDs(derr.id, apiclient)
+		if err != nil {
+			d.Errors = append(d.Errors, err.Error())
+		}
+		appIDs = ids
+	}
+
+	var queue struct {
+		Queue []queueItem `json:"queue"`
+	}
+	if queueJSON, err := apiclient.GetQueueJSON(); err != nil {
+		d.Errors = append(d.Errors, err.Error())
+	} else if err = json.Unmarshal(queueJSON, &queue); err != nil {
+		d.Errors = append(d.Errors, err.Error())
+	}
+
+	for _, id := range appIDs {
+		ad := appDiagnostics{ID: normalizeID(id), Kind: kindApp}
+
+		if app, err := apiclient.GetApp(id, ""); err != nil {
+			d.Errors = append(d.Errors, err.Error())
+		} else {
+			ad.LastTaskFailure = app.LastTaskFailure
+		}
+
+		var tasks struct {
+			Tasks []task `json:"tasks"`
+		}
+		if tasksJSON, err := apiclient.GetAppTasksJSON(id); err != nil {
+			d.Errors = append(d.Errors, err.Error())
+		} else if err = json.Unmarshal(tasksJSON, &tasks); err != nil {
+			d.Errors = append(d.Errors, err.Error())
+		}
+		ad.Tasks = tasks.Tasks
+
+		ad.Queue = queuedItem(queue.Queue, ad.ID)
+		d.Apps = append(d.Apps, ad)
+	}
+
+	for _, id := range podIDs {
+		pd := appDiagnostics{ID: normalizeID(id), Kind: kindPod}
+
+		// A pod's instances stand in for the tasks of an app.
+		var status podStatus
+		if statusJSON, err := apiclient.GetPodStatusJSON(id); err != nil {
+			d.Errors = append(d.Errors, err.Error())
+		} else if err = json.Unmarshal(statusJSON, &status); err != nil {
+			d.Errors = append(d.Errors, err.Error())
+		}
+		for _, i := range status.Instances {
+			pd.Tasks = append(pd.Tasks, task{
+				ID:      i.ID,
+				Host:    i.AgentHostname,
+				State:   i.Status,
+				Version: path.Base(i.SpecReference),
+			})
+		}
+
+		pd.Queue = queuedItem(queue.Queue, pd.ID)
+		d.Apps = append(d.Apps, pd)
+	}
+
+	return d
+}
+
+// queuedItem returns the launch queue entry of an app or pod, if it has one.
+func queuedItem(queue []queueItem, id string) *queueItem {
+	for i := range queue {
+		if normalizeID(queue[i].id()) == id {
+			return &queue[i]
+		}
+	}
+	return nil
+}
+
+// groupAppIDs returns the IDs of the apps in a group and its subgroups.
+func groupAppIDs(groupID string, apiclient marathon.Marathoner) ([]string, error) {
+	groupJSON, err := apiclient.GetGroupJSON(groupID, "")
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	return ids, collectAppIDs(groupJSON, &ids)
+}
+
+func collectAppIDs(groupJSON []byte, ids *[]string) error {
+	var g struct {
+		Apps []struct {
+			ID string `json:"id"`
+		} `json:"apps"`
+		Groups []json.RawMessage `json:"groups"`
+	}
+	if err := json.Unmarshal(groupJSON, &g); err != nil {
+		return err
+	}
+	for _, app := range g.Apps {
+		*ids = append(*ids, app.ID)
+	}
+	for _, sub := range g.Groups {
+		if err := collectAppIDs(sub, ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d diagnostics) print(w io.Writer) {
+	fmt.Fprintf(w, "Deployment %s failed: %s\n", d.DeploymentID, d.Error)
+	if dep := d.Deployment; dep != nil {
+		fmt.Fprintf(w, "  Stopped at step %d of %d\n", dep.CurrentStep, dep.TotalSteps)
+		for i, step := range dep.Steps {
+			fmt.Fprintf(w, "    %d. %s\n", i+1, marathon.DescribeActions(step))
+		}
+	}
+
+	for _, app := range d.Apps {
+		if app.Kind == kindPod {
+			fmt.Fprintf(w, "Pod %s\n", app.ID)
+		} else {
+			fmt.Fprintf(w, "App %s\n", app.ID)
+		}
+		if f := app.LastTaskFailure; f != nil {
+			fmt.Fprintf(
+				w,
+				"  Last task failure: %s %s on %s at %s: %s\n",
+				f.TaskID,
+				f.State,
+				f.Host,
+				f.Timestamp,
+				f.Message,
+			)
+		}
+		if q := app.Queue; q != nil {
+			fmt.Fprintf(
+				w,
+				"  Waiting to launch %d instances with %d seconds of launch delay left\n",
+				q.Count,
+				q.Delay.TimeLeftSeconds,
+			)
+			if s := q.ProcessedOffersSummary; s != nil {
+				fmt.Fprintf(
+					w,
+					"  Declined %d of the last %d offers\n",
+					s.UnusedOffersCount,
+					s.ProcessedOffersCount,
+				)
+				for _, r := range s.RejectSummaryLastOffers {
+					if r.Declined > 0 {
+						fmt.Fprintf(w, "    %s: %d of %d\n", r.Reason, r.Declined, r.Processed)
+					}
+				}
+			}
+		}
+		if len(app.Tasks) == 0 {
+			fmt.Fprintln(w, "  No tasks are running")
+		}
+		for _, t := range app.Tasks {
+			fmt.Fprintf(w, "  Task %s is %s on %s at version %s\n", t.ID, t.State, t.Host, t.Version)
+		}
+	}
+
+	for _, e := range d.Errors {
+		fmt.Fprintf(w, "Unable to collect diagnostics: %s\n", e)
+	}
+}
@@ -0,0 +1,209 @@
+package behaviors
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
+	gomarathon "github.com/gambol99/go-marathon"
+	"github.com/golang/mock/gomock"
+)
+
+// allowDiagnostics lets failed deployments be diagnosed without printing
+// anything or leaving reports behind.
+func allowDiagnostics(t *testing.T, m *mocks.MockMarathoner) func() {
+	m.EXPECT().GetDeployment(gomock.Any()).AnyTimes().Return(nil, nil)
+	m.EXPECT().GetQueueJSON().AnyTimes().Return(json.RawMessage(`{"queue":[]}`), nil)
+	m.EXPECT().GetApp(gomock.Any(), "").AnyTimes().Return(gomarathon.Application{}, nil)
+	m.EXPECT().GetAppTasksJSON(gomock.Any()).AnyTimes().Return(json.RawMessage(`{"tasks":[]}`), nil)
+	m.EXPECT().GetGroupJSON(gomock.Any(), "").AnyTimes().Return(json.RawMessage(`{}`), nil)
+	m.EXPECT().GetPodStatusJSON(gomock.Any()).AnyTimes().Return(json.RawMessage(`{}`), nil)
+
+	dir, err := ioutil.TempDir("", "marathon-resource-reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, reports := buildLog, reportDir
+	buildLog, reportDir = ioutil.Discard, dir
+	return func() {
+		buildLog, reportDir = output, reports
+		os.RemoveAll(dir)
+	}
+}
+
+func Test_reportDiagnostics(t *testing.T) {
+	var (
+		ctrl           = gomock.NewController(t)
+		mockMarathoner = mocks.NewMockMarathoner(ctrl)
+		out            = &bytes.Buffer{}
+		instances      = 2
+	)
+	defer ctrl.Finish()
+	defer func(w io.Writer) { buildLog = w }(buildLog)
+	buildLog = out
+
+	dir, err := ioutil.TempDir("", "marathon-resource-diagnostics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(d string) { reportDir = d }(reportDir)
+	reportDir = dir
+
+	mockMarathoner.EXPECT().GetQueueJSON().Times(1).Return(json.RawMessage(`{"queue":[
+		{"count":2,"delay":{"timeLeftSeconds":30,"overdue":false},"app":{"id":"/foo"},
+		 "processedOffersSummary":{"processedOffersCount":4,"unusedOffersCount":4,"rejectSummaryLastOffers":[
+			{"reason":"InsufficientMemory","declined":4,"processed":4},
+			{"reason":"InsufficientCpus","declined":0,"processed":4}]}},
+		{"count":1,"delay":{"timeLeftSeconds":0,"overdue":true},"pod":{"id":"/bar"}}]}`), nil)
+	mockMarathoner.EXPECT().GetApp("/foo", "").Times(1).Return(gomarathon.Application{
+		ID:        "/foo",
+		Instances: &instances,
+		LastTaskFailure: &gomarathon.LastTaskFailure{
+			TaskID:    "foo.1",
+			State:     "TASK_FAILED",
+			Host:      "agent-1",
+			Timestamp: "2017-01-01T00:00:00.000Z",
+			Message:   "Command exited with status 1",
+		},
+	}, nil)
+	mockMarathoner.EXPECT().GetAppTasksJSON("/foo").Times(1).Return(json.RawMessage(
+		`{"tasks":[{"id":"foo.2","host":"agent-2","state":"TASK_STAGING","version":"v2"}]}`,
+	), nil)
+	mockMarathoner.EXPECT().GetApp("/baz", "").Times(1).Return(gomarathon.Application{}, errors.New("no app"))
+	mockMarathoner.EXPECT().GetAppTasksJSON("/baz").Times(1).Return(json.RawMessage(`{"tasks":[]}`), nil)
+
+	path := reportDiagnostics(
+		&deploymentError{
+			deploymentID: "dep",
+			id:           "foo",
+			deployment: &gomarathon.Deployment{
+				ID:           "dep",
+				CurrentStep:  2,
+				TotalSteps:   2,
+				AffectedApps: []string{"/foo", "/baz"},
+				Steps: [][]*gomarathon.DeploymentStep{
+					{{Action: "StartApplication", App: "/foo"}},
+					{{Action: "ScaleApplication", App: "/foo"}, {Action: "ScaleApplication", App: "/baz"}},
+				},
+			},
+			err: errors.New("Could not deploy"),
+		},
+		mockMarathoner,
+	)
+	if filepath.Dir(filepath.Dir(path)) != dir || filepath.Base(path) != diagnosticsFile {
+		t.Errorf("reportDiagnostics() wrote %s, want a %s under %s", path, diagnosticsFile, dir)
+	}
+
+	for _, want := range []string{
+		"Deployment dep failed: Could not deploy",
+		"Stopped at step 2 of 2",
+		"2. ScaleApplication /foo, ScaleApplication /baz",
+		"Last task failure: foo.1 TASK_FAILED on agent-1 at 2017-01-01T00:00:00.000Z: Command exited with status 1",
+		"Waiting to launch 2 instances with 30 seconds of launch delay left",
+		"Declined 4 of the last 4 offers",
+		"InsufficientMemory: 4 of 4",
+		"Task foo.2 is TASK_STAGING on agent-2 at version v2",
+		"App /baz\n  No tasks are running",
+		"Unable to collect diagnostics: no app",
+		"Diagnostics written to " + path,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("reportDiagnostics() printed\n%s\nwant it to contain %q", out, want)
+		}
+	}
+	if strings.Contains(out.String(), "InsufficientCpus") {
+		t.Errorf("reportDiagnostics() printed reasons no offers were declined for\n%s", out)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got diagnostics
+	if err = json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Deployment == nil || got.Deployment.CurrentStep != 2 || len(got.Apps) != 2 {
+		t.Errorf("reportDiagnostics() wrote %s", b)
+	}
+	if q := got.Apps[0].Queue; q == nil || q.Count != 2 || q.ProcessedOffersSummary.UnusedOffersCount != 4 {
+		t.Errorf("reportDiagnostics() wrote queue %+v", q)
+	}
+	if want := []string{"no app"}; !reflect.DeepEqual(got.Errors, want) {
+		t.Errorf("reportDiagnostics() wrote errors %v, want %v", got.Errors, want)
+	}
+}
+
+func Test_checkDeploymentLoop_diagnostics(t *testing.T) {
+	var (
+		ctrl           = gomock.NewController(t)
+		mockMarathoner = mocks.NewMockMarathoner(ctrl)
+		deployment     = &gomarathon.Deployment{ID: "foo"}
+	)
+	defer ctrl.Finish()
+
+	gomock.InOrder(
+		mockMarathoner.EXPECT().WaitDeployment("foo", gomock.Any()).Times(1).Return(false, marathon.ErrDeploymentFailed),
+		mockMarathoner.EXPECT().WaitDeployment("foo", gomock.Any()).Times(1).Return(true, nil),
+		mockMarathoner.EXPECT().GetDeployment("foo").Times(1).Return(deployment, nil),
+		mockMarathoner.EXPECT().DeleteDeployment("foo", true).Times(1).Return(gomarathon.DeploymentID{}, nil),
+	)
+
+	err := checkDeploymentLoop("foo", "/app", Params{TimeOut: 2}, mockMarathoner)
+	if derr, ok := err.(*deploymentError); !ok || derr.err != marathon.ErrDeploymentFailed || derr.id != "/app" {
+		t.Errorf("checkDeploymentLoop() error = %#v, want the failed deployment", err)
+	}
+
+	err = checkDeploymentLoop("foo", "/app", Params{TimeOut: 2, OnTimeout: timeoutForceCancel}, mockMarathoner)
+	if derr, ok := err.(*deploymentError); !ok || derr.deployment != deployment {
+		t.Errorf("checkDeploymentLoop() error = %#v, want the timed out deployment", err)
+	}
+}
+
+func Test_gatherDiagnostics_kinds(t *testing.T) {
+	var (
+		ctrl           = gomock.NewController(t)
+		mockMarathoner = mocks.NewMockMarathoner(ctrl)
+		noTasks        = json.RawMessage(`{"tasks":[]}`)
+	)
+	defer ctrl.Finish()
+
+	mockMarathoner.EXPECT().GetQueueJSON().Times(2).Return(json.RawMessage(`{"queue":[]}`), nil)
+	gomock.InOrder(
+		mockMarathoner.EXPECT().GetGroupJSON("/shop", "").Times(1).Return(json.RawMessage(
+			`{"id":"/shop","apps":[{"id":"/shop/db"}],"groups":[{"id":"/shop/web","apps":[{"id":"/shop/web/api"}]}]}`,
+		), nil),
+		mockMarathoner.EXPECT().GetApp("/shop/db", "").Times(1).Return(gomarathon.Application{}, nil),
+		mockMarathoner.EXPECT().GetAppTasksJSON("/shop/db").Times(1).Return(noTasks, nil),
+		mockMarathoner.EXPECT().GetApp("/shop/web/api", "").Times(1).Return(gomarathon.Application{}, nil),
+		mockMarathoner.EXPECT().GetAppTasksJSON("/shop/web/api").Times(1).Return(noTasks, nil),
+		mockMarathoner.EXPECT().GetPodStatusJSON("/web").Times(1).Return(json.RawMessage(
+			`{"id":"/web","instances":[{"id":"web.instance-1","status":"PENDING","agentHostname":"agent-1","specReference":"/v2/pods/web::versions/v2"}]}`,
+		), nil),
+	)
+
+	group := gatherDiagnostics(&deploymentError{id: "/shop", kind: kindGroup, err: errors.New("failed")}, mockMarathoner)
+	if len(group.Apps) != 2 || group.Apps[0].ID != "/shop/db" || group.Apps[1].ID != "/shop/web/api" || len(group.Errors) > 0 {
+		t.Errorf("gatherDiagnostics() for a group = %+v", group)
+	}
+
+	pod := gatherDiagnostics(&deploymentError{id: "/web", kind: kindPod, err: errors.New("failed")}, mockMarathoner)
+	want := []appDiagnostics{{
+		ID:    "/web",
+		Kind:  kindPod,
+		Tasks: []task{{ID: "web.instance-1", Host: "agent-1", State: "PENDING", Version: "v2"}},
+	}}
+	if !reflect.DeepEqual(pod.Apps, want) || len(pod.Errors) > 0 {
+		t.Errorf("gatherDiagnostics() for a pod = %+v, want apps %+v", pod, want)
+	}
+}
@@ -0,0 +1,224 @@
+package behaviors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+)
+
+var (
+	// replacedFields are objects Marathon replaces as a whole, so keys left
+	// out of the definition are removed. Keys left out of any other object
+	// keep their live value or get Marathon's default.
+	replacedFields = map[string]bool{
+		"env":    true,
+		"labels": true,
+	}
+
+	// unorderedFields are lists whose order Marathon doesn't care about.
+	unorderedFields = map[string]bool{
+		"acceptedResourceRoles": true,
+		"constraints":           true,
+		"dependencies":          true,
+		"env":                   true,
+		"labels":                true,
+	}
+)
+
+// dryRun prints how the rendered app differs from the running one and writes
+// the same to a report file instead of deploying it. The file's path is in
+// the metadata.
+func dryRun(
+	appID string,
+	appJSON []byte,
+	apiclient marathon.Marathoner,
+) (IOOutput, error) {
+	liveJSON, err := apiclient.GetAppJSON(appID, "")
+	if apiErr, ok := err.(*marathon.APIError); ok && apiErr.StatusCode == http.StatusNotFound {
+		// Everything is new to an app that doesn't exist yet.
+		liveJSON, err = []byte(`{}`), nil
+	}
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	changes, err := diffApps(liveJSON, appJSON)
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	diff := fmt.Sprintf("Changes to %s:\n", normalizeID(appID))
+	if len(changes) == 0 {
+		diff += "No changes\n"
+	}
+	for _, c := range changes {
+		diff += c + "\n"
+	}
+	fmt.Fprint(buildLog, diff)
+	diffPath, err := writeReport(diffFile, []byte(diff))
+	if err != nil {
+		return IOOutput{}, err
+	}
+
+	var live struct {
+		Version string `json:"version"`
+	}
+	if err = json.Unmarshal(liveJSON, &live); err != nil {
+		return IOOutput{}, err
+	}
+	return IOOutput{
+		Version: Version{Ref: live.Version},
+		Metadata: []Metadata{
+			{"dry_run", "true"},
+			{"changes", strconv.Itoa(len(changes))},
+			{"diff_file", diffPath},
+		},
+	}, nil
+}
+
+// diffApps lists the changes deploying the rendered app would make to the
+// live one. Each change is a line starting with `+` for an added value, `-`
+// for a removed one or `~` for a changed one.
+func diffApps(liveJSON, renderedJSON []byte) ([]string, error) {
+	var live, rendered map[string]interface{}
+	if err := json.Unmarshal(liveJSON, &live); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(renderedJSON, &rendered); err != nil {
+		return nil, err
+	}
+
+	for _, field := range runtimeFields {
+		delete(live, field)
+		delete(rendered, field)
+	}
+	for _, app := range []map[string]interface{}{live, rendered} {
+		if id, ok := app["id"].(string); ok {
+			app["id"] = normalizeID(id)
+		}
+	}
+
+	var changes []string
+	diffValues("", "", live, rendered, &changes)
+	return changes, nil
+}
+
+func diffValues(
+	path string,
+	field string,
+	live interface{},
+	rendered interface{},
+	changes *[]string,
+) {
+	switch r := rendered.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, k := range sortedKeys(r) {
+			lv, ok := l[k]
+			if !ok {
+				addChange(changes, "+", joinPath(path, k), r[k])
+				continue
+			}
+			diffValues(joinPath(path, k), k, lv, r[k], changes)
+		}
+		if replacedFields[field] {
+			for _, k := range sortedKeys(l) {
+				if _, ok := r[k]; !ok {
+					addChange(changes, "-", joinPath(path, k), l[k])
+				}
+			}
+		}
+		return
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok {
+			break
+		}
+		if unorderedFields[field] {
+			diffSets(path, l, r, changes)
+			return
+		}
+		for i := 0; i < len(l) || i < len(r); i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(l):
+				addChange(changes, "+", elemPath, r[i])
+			case i >= len(r):
+				addChange(changes, "-", elemPath, l[i])
+			default:
+				diffValues(elemPath, field, l[i], r[i], changes)
+			}
+		}
+		return
+	default:
+		if reflect.DeepEqual(live, rendered) {
+			return
+		}
+	}
+	*changes = append(
+		*changes,
+		fmt.Sprintf("~ %s: %s => %s", path, toJSON(live), toJSON(rendered)),
+	)
+}
+
+// diffSets compares lists as if they were sets.
+func diffSets(path string, live, rendered []interface{}, changes *[]string) {
+	count := map[string]int{}
+	for _, v := range live {
+		count[toJSON(v)]++
+	}
+	for _, v := range rendered {
+		count[toJSON(v)]--
+	}
+	for _, v := range rendered {
+		if k := toJSON(v); count[k] < 0 {
+			count[k]++
+			addChange(changes, "+", path+"[]", v)
+		}
+	}
+	for _, v := range live {
+		if k := toJSON(v); count[k] > 0 {
+			count[k]--
+			addChange(changes, "-", path+"[]", v)
+		}
+	}
+}
+
+func addChange(changes *[]string, op, path string, value interface{}) {
+	*changes = append(*changes, fmt.Sprintf("%s %s: %s", op, path, toJSON(value)))
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// toJSON formats a value for the diff. Maps are marshaled with sorted keys so
+// equal values always look the same.
+func toJSON(v interface{}) string {
+	b, err := encodeDefinition(v)
+	if err != nil {
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+	return string(b)
+}
@@ -0,0 +1,166 @@
+package behaviors
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
+	"github.com/golang/mock/gomock"
+)
+
+func Test_diffApps(t *testing.T) {
+	tests := []struct {
+		name     string
+		live     string
+		rendered string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			"No changes",
+			`{"id":"/foo","instances":2,"version":"v1","tasksRunning":2,"backoffSeconds":1,"env":{"A":"1","B":"2"}}`,
+			`{"id":"foo","instances":2,"env":{"B":"2","A":"1"}}`,
+			nil,
+			false,
+		},
+		{
+			"Changed values",
+			`{"id":"/foo","instances":2,"cmd":"sleep 1","container":{"docker":{"image":"foo:1","network":"BRIDGE"}}}`,
+			`{"id":"/foo","instances":3,"cmd":"sleep 1","container":{"docker":{"image":"foo:2"}},"cpus":0.5}`,
+			[]string{
+				`~ container.docker.image: "foo:1" => "foo:2"`,
+				`+ cpus: 0.5`,
+				`~ instances: 2 => 3`,
+			},
+			false,
+		},
+		{
+			"Env and labels are replaced",
+			`{"id":"/foo","env":{"A":"1","OLD":"x"},"labels":{"team":"a"}}`,
+			`{"id":"/foo","env":{"A":"2"},"labels":{"team":"a","tier":"web"}}`,
+			[]string{
+				`~ env.A: "1" => "2"`,
+				`- env.OLD: "x"`,
+				`+ labels.tier: "web"`,
+			},
+			false,
+		},
+		{
+			"Unordered lists",
+			`{"id":"/foo","constraints":[["hostname","UNIQUE"],["rack","GROUP_BY"]],"args":["a","b"]}`,
+			`{"id":"/foo","constraints":[["rack","GROUP_BY"],["zone","LIKE","a"]],"args":["b","a","c"]}`,
+			[]string{
+				`~ args[0]: "a" => "b"`,
+				`~ args[1]: "b" => "a"`,
+				`+ args[2]: "c"`,
+				`+ constraints[]: ["zone","LIKE","a"]`,
+				`- constraints[]: ["hostname","UNIQUE"]`,
+			},
+			false,
+		},
+		{
+			"Changed type",
+			`{"id":"/foo","portDefinitions":[{"port":0}]}`,
+			`{"id":"/foo","portDefinitions":{"port":0}}`,
+			[]string{`~ portDefinitions: [{"port":0}] => {"port":0}`},
+			false,
+		},
+		{
+			"Bad JSON",
+			`{"id":"/foo"}`,
+			`{]`,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := diffApps([]byte(tt.live), []byte(tt.rendered))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. diffApps() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. diffApps() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOut_dryRun(t *testing.T) {
+	var (
+		ctrl           = gomock.NewController(t)
+		mockMarathoner = mocks.NewMockMarathoner(ctrl)
+		out            = &bytes.Buffer{}
+	)
+	defer ctrl.Finish()
+	defer func(w io.Writer) { buildLog = w }(buildLog)
+	buildLog = out
+
+	dir, err := ioutil.TempDir("", "marathon-resource-dry-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(d string) { reportDir = d }(reportDir)
+	reportDir = filepath.Join(dir, "reports")
+	if err = os.Mkdir(reportDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	appJSON := []byte(`{"id":"foo","instances":2,"cmd":"sleep 1"}`)
+	if err = ioutil.WriteFile(filepath.Join(dir, "app.json"), appJSON, 0644); err != nil {
+		t.Fatal(err)
+	}
+	liveJSON := []byte(`{"id":"/foo","instances":7,"cmd":"sleep 1","version":"v1","tasksRunning":7}`)
+
+	gomock.InOrder(
+		mockMarathoner.EXPECT().GetAppJSON("foo", "").Times(1).Return(json.RawMessage(liveJSON), nil),
+		mockMarathoner.EXPECT().GetAppJSON("foo", "").Times(1).Return(nil, &marathon.APIError{StatusCode: http.StatusNotFound}),
+	)
+
+	input := InputJSON{Params: Params{AppJSON: AppJSONPaths{"app.json"}, TimeOut: 2, DryRun: true}}
+	got, err := Out(input, dir, mockMarathoner)
+	if err != nil {
+		t.Fatalf("Out() error = %v", err)
+	}
+	if len(got.Metadata) != 3 || filepath.Base(got.Metadata[2].Value) != diffFile {
+		t.Fatalf("Out() metadata = %v, want the diff file last", got.Metadata)
+	}
+	diffPath := got.Metadata[2].Value
+	want := IOOutput{
+		Version:  Version{Ref: "v1"},
+		Metadata: []Metadata{{"dry_run", "true"}, {"changes", "1"}, {"diff_file", diffPath}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Out() = %v, want %v", got, want)
+	}
+	if !strings.HasPrefix(diffPath, reportDir) {
+		t.Errorf("Out() wrote the diff to %s, want it under %s", diffPath, reportDir)
+	}
+	if _, err = os.Stat(filepath.Join(dir, diffFile)); !os.IsNotExist(err) {
+		t.Errorf("Out() wrote the diff next to app_json")
+	}
+	diff, err := ioutil.ReadFile(diffPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(diff, []byte("~ instances: 7 => 2\n")) || !bytes.Equal(diff, out.Bytes()) {
+		t.Errorf("Out() wrote diff %q and printed %q", diff, out)
+	}
+
+	if got, err = Out(input, dir, mockMarathoner); err != nil || got.Version.Ref != "" {
+		t.Errorf("Out() = %v, %v for a new app", got, err)
+	}
+
+	input.Source.Kind = kindGroup
+	if _, err = Out(input, dir, mockMarathoner); err == nil {
+		t.Error("Out() expected an error for a dry run of a group")
+	}
+}
@@ -0,0 +1,25 @@
+package behaviors
+
+import "fmt"
+
+// causeError adds to an error's message while keeping the error as its
+// cause, so the Marathon API error underneath is still logged in full.
+type causeError struct {
+	msg   string
+	cause error
+}
+
+func (e *causeError) Error() string {
+	return e.msg
+}
+
+// Cause returns the wrapped error.
+func (e *causeError) Cause() error {
+	return e.cause
+}
+
+// withCause formats a message for err like fmt.Errorf and keeps err as the
+// cause.
+func withCause(err error, format string, args ...interface{}) error {
+	return &causeError{msg: fmt.Sprintf(format, args...), cause: err}
+}
@@ -0,0 +1,25 @@
+package behaviors
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+)
+
+func Test_withCleanup_cause(t *testing.T) {
+	apiErr := &marathon.APIError{StatusCode: http.StatusConflict}
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"Plain error", withCleanup(apiErr, []string{"removed /foo"})},
+		{"Deployment error", withCleanup(&deploymentError{err: apiErr}, []string{"removed /foo"})},
+		{"Formatted error", withCause(apiErr, "Version v1 of /foo not found: %v", apiErr)},
+	}
+	for _, tt := range tests {
+		if got := marathon.Cause(tt.err); got != apiErr {
+			t.Errorf("%q. marathon.Cause() = %v, want the API error", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package behaviors
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	gomarathon "github.com/gambol99/go-marathon"
+)
+
+const (
+	appFile           = "app.json"
+	promotableAppFile = "promotable_app.json"
+	groupFile         = "group.json"
+	podFile           = "pod.json"
+	versionFile       = "version"
+	metadataFile      = "metadata.json"
+	diagnosticsFile   = "diagnostics.json"
+	diffFile          = "diff.txt"
+)
+
+// runtimeFields are set by Marathon on a running app and are rejected or
+// ignored when the definition is sent back to it.
+var runtimeFields = []string{
+	"deployments",
+	"lastTaskFailure",
+	"readinessCheckResults",
+	"taskStats",
+	"tasks",
+	"tasksHealthy",
+	"tasksRunning",
+	"tasksStaged",
+	"tasksUnhealthy",
+	"version",
+	"versionInfo",
+}
+
+// promotableApp strips the runtime-only fields from an app definition and
+// normalizes its ID so it can be deployed again by `out`.
+func promotableApp(appJSON []byte) ([]byte, error) {
+	var app map[string]json.RawMessage
+	if err := json.Unmarshal(appJSON, &app); err != nil {
+		return nil, err
+	}
+	for _, field := range runtimeFields {
+		delete(app, field)
+	}
+
+	if rawID, ok := app["id"]; ok {
+		var id string
+		if err := json.Unmarshal(rawID, &id); err != nil {
+			return nil, err
+		}
+		normalized, err := json.Marshal(normalizeID(id))
+		if err != nil {
+			return nil, err
+		}
+		app["id"] = normalized
+	}
+
+	promotable, err := encodeDefinition(app)
+	if err != nil {
+		return nil, err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, promotable, "", "  "); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+func normalizeID(id string) string {
+	return "/" + strings.Trim(id, "/")
+}
+
+type (
+	// group holds the parts of a group definition shown in the metadata.
+	group struct {
+		ID      string            `json:"id"`
+		Version string            `json:"version"`
+		Apps    []json.RawMessage `json:"apps"`
+		Groups  []json.RawMessage `json:"groups"`
+	}
+
+	// pod holds the parts of a pod definition shown in the metadata.
+	pod struct {
+		ID      string `json:"id"`
+		Version string `json:"version"`
+		Scaling *struct {
+			Instances int `json:"instances"`
+		} `json:"scaling"`
+		Containers []json.RawMessage `json:"containers"`
+	}
+)
+
+func groupMetadata(groupJSON []byte) (string, []Metadata, error) {
+	var g group
+	if err := json.Unmarshal(groupJSON, &g); err != nil {
+		return "", nil, err
+	}
+	return g.Version, []Metadata{
+		{Name: "id", Value: g.ID},
+		{Name: "version", Value: g.Version},
+		{Name: "apps", Value: strconv.Itoa(len(g.Apps))},
+		{Name: "groups", Value: strconv.Itoa(len(g.Groups))},
+	}, nil
+}
+
+func podMetadata(podJSON []byte) (string, []Metadata, error) {
+	var p pod
+	if err := json.Unmarshal(podJSON, &p); err != nil {
+		return "", nil, err
+	}
+	metadata := []Metadata{
+		{Name: "id", Value: p.ID},
+		{Name: "version", Value: p.Version},
+		{Name: "containers", Value: strconv.Itoa(len(p.Containers))},
+	}
+	if p.Scaling != nil {
+		metadata = append(
+			metadata,
+			Metadata{Name: "instances", Value: strconv.Itoa(p.Scaling.Instances)},
+		)
+	}
+	return p.Version, metadata, nil
+}
+
+func appMetadata(app gomarathon.Application) []Metadata {
+	metadata := []Metadata{
+		{Name: "id", Value: app.ID},
+		{Name: "version", Value: app.Version},
+	}
+	if app.Instances != nil {
+		metadata = append(
+			metadata,
+			Metadata{Name: "instances", Value: strconv.Itoa(*app.Instances)},
+		)
+	}
+	if app.Container != nil && app.Container.Docker != nil {
+		metadata = append(
+			metadata,
+			Metadata{Name: "image", Value: app.Container.Docker.Image},
+		)
+	}
+	return metadata
+}
+
+// writeReport writes data to a file with the given name in a new directory
+// under reportDir and returns its path.
+func writeReport(name string, data []byte) (string, error) {
+	dir, err := ioutil.TempDir(reportDir, "marathon-resource")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	return path, ioutil.WriteFile(path, data, 0644)
+}
+
+// writeInFiles writes each of the definitions fetched by `in` to the
+// destination along with their version and metadata.
+func writeInFiles(
+	destination string,
+	definitions map[string][]byte,
+	output IOOutput,
+) error {
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		return err
+	}
+
+	for name, definition := range definitions {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, definition, "", "  "); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(
+			filepath.Join(destination, name),
+			buf.Bytes(),
+			0644,
+		); err != nil {
+			return err
+		}
+	}
+
+	if err := ioutil.WriteFile(
+		filepath.Join(destination, versionFile),
+		[]byte(output.Version.Ref),
+		0644,
+	); err != nil {
+		return err
+	}
+
+	metadata, err := json.MarshalIndent(output.Metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(
+		filepath.Join(destination, metadataFile),
+		metadata,
+		0644,
+	)
+}
@@ -0,0 +1,147 @@
+package behaviors
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gomarathon "github.com/gambol99/go-marathon"
+)
+
+func Test_appMetadata(t *testing.T) {
+	instances := 3
+	tests := []struct {
+		name string
+		app  gomarathon.Application
+		want []Metadata
+	}{
+		{
+			"Minimal app",
+			gomarathon.Application{ID: "/foo", Version: "bar"},
+			[]Metadata{{"id", "/foo"}, {"version", "bar"}},
+		},
+		{
+			"Docker app",
+			gomarathon.Application{
+				ID:        "/foo",
+				Version:   "bar",
+				Instances: &instances,
+				Container: &gomarathon.Container{
+					Docker: &gomarathon.Docker{Image: "nginx:1.11"},
+				},
+			},
+			[]Metadata{
+				{"id", "/foo"},
+				{"version", "bar"},
+				{"instances", "3"},
+				{"image", "nginx:1.11"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := appMetadata(tt.app); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. appMetadata() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_writeInFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "marathon-resource-files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	type args struct {
+		destination string
+		definitions map[string][]byte
+		output      IOOutput
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			"Works",
+			args{
+				filepath.Join(dir, "get"),
+				map[string][]byte{
+					appFile:   []byte(`{"id":"/foo","secrets":{}}`),
+					groupFile: []byte(`{"id":"/bar"}`),
+				},
+				IOOutput{
+					Version:  Version{Ref: "bar"},
+					Metadata: []Metadata{{"id", "/foo"}},
+				},
+			},
+			map[string]string{
+				appFile:      "{\n  \"id\": \"/foo\",\n  \"secrets\": {}\n}",
+				groupFile:    "{\n  \"id\": \"/bar\"\n}",
+				versionFile:  "bar",
+				metadataFile: "[\n  {\n    \"name\": \"id\",\n    \"value\": \"/foo\"\n  }\n]",
+			},
+			false,
+		},
+		{
+			"Bad JSON",
+			args{filepath.Join(dir, "bad"), map[string][]byte{appFile: []byte(`{]`)}, IOOutput{}},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		err := writeInFiles(tt.args.destination, tt.args.definitions, tt.args.output)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. writeInFiles() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		for name, want := range tt.want {
+			got, err := ioutil.ReadFile(filepath.Join(tt.args.destination, name))
+			if err != nil {
+				t.Errorf("%q. writeInFiles() did not write %s: %v", tt.name, name, err)
+				continue
+			}
+			if string(got) != want {
+				t.Errorf("%q. writeInFiles() wrote %s = %q, want %q", tt.name, name, got, want)
+			}
+		}
+	}
+}
+
+func Test_promotableApp(t *testing.T) {
+	tests := []struct {
+		name    string
+		appJSON string
+		want    string
+		wantErr bool
+	}{
+		{
+			"Strips runtime fields",
+			`{"id":"foo/bar/","instances":2,"tasks":[{"id":"t1"}],"tasksRunning":2,"deployments":[],"version":"2016-01-01T00:00:00Z","versionInfo":{},"lastTaskFailure":{}}`,
+			"{\n  \"id\": \"/foo/bar\",\n  \"instances\": 2\n}",
+			false,
+		},
+		{
+			"Keeps unknown fields",
+			`{"id":"/foo","networks":[{"mode":"host"}]}`,
+			"{\n  \"id\": \"/foo\",\n  \"networks\": [\n    {\n      \"mode\": \"host\"\n    }\n  ]\n}",
+			false,
+		},
+		{"Bad JSON", `{]`, "", true},
+		{"Bad ID", `{"id":1}`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := promotableApp([]byte(tt.appJSON))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. promotableApp() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%q. promotableApp() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package behaviors
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+	gomarathon "github.com/gambol99/go-marathon"
+)
+
+// healthPollInterval is how often the app is checked while waiting for it to
+// settle.
+var healthPollInterval = time.Second
+
+// waitHealthy waits until every instance of the app at version is healthy
+// and has stayed that way for `stable_period` seconds. It gives up after
+// `time_out` seconds.
+func waitHealthy(
+	appID string,
+	version string,
+	params Params,
+	apiclient marathon.Marathoner,
+) error {
+	var (
+		stablePeriod = time.Duration(params.StablePeriod) * time.Second
+		deadline     = time.Now().Add(time.Duration(params.TimeOut) * time.Second)
+		healthySince time.Time
+	)
+	for {
+		app, err := apiclient.GetApp(appID, "")
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch {
+		case !isHealthy(app, version):
+			healthySince = time.Time{}
+		case healthySince.IsZero():
+			healthySince = now
+		}
+		if !healthySince.IsZero() && now.Sub(healthySince) >= stablePeriod {
+			return nil
+		}
+		if now.After(deadline) {
+			return fmt.Errorf(
+				"App %s did not stay healthy for %d seconds within %d seconds: %d of %d instances healthy, %d unhealthy, failing tasks: %s",
+				appID,
+				params.StablePeriod,
+				params.TimeOut,
+				app.TasksHealthy,
+				instances(app),
+				app.TasksUnhealthy,
+				strings.Join(failingTasks(app, version), ", "),
+			)
+		}
+		time.Sleep(healthPollInterval)
+	}
+}
+
+// isHealthy reports whether all instances of the app run the version and
+// pass their health checks. Apps without health checks only need their
+// tasks running.
+func isHealthy(app gomarathon.Application, version string) bool {
+	if app.Version != version || app.TasksUnhealthy > 0 {
+		return false
+	}
+	if !app.HasHealthChecks() {
+		return app.TasksRunning == instances(app)
+	}
+	return app.TasksHealthy == instances(app)
+}
+
+// failingTasks returns the IDs of the tasks keeping the app from being
+// healthy.
+func failingTasks(app gomarathon.Application, version string) []string {
+	var failing []string
+	for _, task := range app.Tasks {
+		if task.Version != version || !taskHealthy(app, task) {
+			failing = append(failing, task.ID)
+		}
+	}
+	if len(failing) == 0 {
+		return []string{"none"}
+	}
+	return failing
+}
+
+func taskHealthy(app gomarathon.Application, task *gomarathon.Task) bool {
+	if !app.HasHealthChecks() {
+		return true
+	}
+	if len(task.HealthCheckResults) == 0 {
+		return false
+	}
+	for _, result := range task.HealthCheckResults {
+		if result == nil || !result.Alive {
+			return false
+		}
+	}
+	return true
+}
+
+func instances(app gomarathon.Application) int {
+	if app.Instances == nil {
+		return 0
+	}
+	return *app.Instances
+}
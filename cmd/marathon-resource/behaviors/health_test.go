@@ -0,0 +1,119 @@
+package behaviors
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
+	gomarathon "github.com/gambol99/go-marathon"
+	"github.com/golang/mock/gomock"
+)
+
+func testApp(version string, healthy, unhealthy int, tasks ...*gomarathon.Task) gomarathon.Application {
+	instances := 2
+	return gomarathon.Application{
+		ID:             "/foo",
+		Version:        version,
+		Instances:      &instances,
+		HealthChecks:   &[]gomarathon.HealthCheck{{Protocol: "HTTP"}},
+		TasksRunning:   len(tasks),
+		TasksHealthy:   healthy,
+		TasksUnhealthy: unhealthy,
+		Tasks:          tasks,
+	}
+}
+
+func testTask(id, version string, alive bool) *gomarathon.Task {
+	return &gomarathon.Task{
+		ID:                 id,
+		Version:            version,
+		HealthCheckResults: []*gomarathon.HealthCheckResult{{Alive: alive}},
+	}
+}
+
+func Test_isHealthy(t *testing.T) {
+	noChecks := testApp("v2", 0, 0, testTask("a", "v2", true), testTask("b", "v2", true))
+	noChecks.HealthChecks = nil
+	tests := []struct {
+		name string
+		app  gomarathon.Application
+		want bool
+	}{
+		{"Healthy", testApp("v2", 2, 0), true},
+		{"Old version", testApp("v1", 2, 0), false},
+		{"Not all healthy", testApp("v2", 1, 0), false},
+		{"Some unhealthy", testApp("v2", 2, 1), false},
+		{"Running without health checks", noChecks, true},
+	}
+	for _, tt := range tests {
+		if got := isHealthy(tt.app, "v2"); got != tt.want {
+			t.Errorf("%q. isHealthy() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_waitHealthy(t *testing.T) {
+	defer func(d time.Duration) { healthPollInterval = d }(healthPollInterval)
+	healthPollInterval = 10 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		params  Params
+		apps    []gomarathon.Application
+		err     error
+		wantErr string
+	}{
+		{
+			"Settles",
+			Params{TimeOut: 5, StablePeriod: 1},
+			[]gomarathon.Application{
+				testApp("v2", 1, 1, testTask("a", "v2", true), testTask("b", "v2", false)),
+				testApp("v2", 2, 0, testTask("a", "v2", true), testTask("c", "v2", true)),
+			},
+			nil,
+			"",
+		},
+		{
+			"Never settles",
+			Params{TimeOut: 0, StablePeriod: 1},
+			[]gomarathon.Application{
+				testApp("v2", 1, 1, testTask("a", "v2", true), testTask("b", "v2", false)),
+			},
+			nil,
+			"failing tasks: b",
+		},
+		{
+			"Error from GetApp",
+			Params{TimeOut: 5, StablePeriod: 1},
+			nil,
+			errors.New("no way"),
+			"no way",
+		},
+	}
+	for _, tt := range tests {
+		ctrl := gomock.NewController(t)
+		mockMarathoner := mocks.NewMockMarathoner(ctrl)
+		if tt.err != nil {
+			mockMarathoner.EXPECT().GetApp("/foo", "").Times(1).Return(gomarathon.Application{}, tt.err)
+		}
+		for i, app := range tt.apps {
+			call := mockMarathoner.EXPECT().GetApp("/foo", "").Return(app, nil)
+			if i == len(tt.apps)-1 {
+				call.AnyTimes()
+			} else {
+				call.Times(1)
+			}
+		}
+
+		err := waitHealthy("/foo", "v2", tt.params, mockMarathoner)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%q. waitHealthy() error = %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%q. waitHealthy() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+		ctrl.Finish()
+	}
+}
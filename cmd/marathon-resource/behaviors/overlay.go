@@ -0,0 +1,377 @@
+package behaviors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// patchOperation is one operation of an RFC 6902 JSON Patch.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (o patchOperation) String() string {
+	s := o.Op
+	if o.From != nil {
+		s += " " + *o.From + " to"
+	}
+	if o.Path != nil {
+		s += " " + *o.Path
+	}
+	return s
+}
+
+// applyOverlays applies the `overlays` to the rendered app in order. An
+// overlay holding an array is a JSON Patch, anything else is a JSON Merge
+// Patch. Overlays are templates rendered the same as the app.
+func applyOverlays(
+	appJSON []byte,
+	overlays []string,
+	path string,
+	replacements map[string]string,
+) ([]byte, error) {
+	var app interface{}
+	if err := decodeDefinition(appJSON, &app); err != nil {
+		return nil, fmt.Errorf("Could not apply overlays, invalid app: %v", err)
+	}
+	for _, overlay := range overlays {
+		rendered, err := renderFile(filepath.Join(path, overlay), replacements)
+		if err != nil {
+			return nil, fmt.Errorf("overlay %s: %v", overlay, err)
+		}
+		var patch interface{}
+		if err = decodeDefinition(rendered, &patch); err != nil {
+			return nil, fmt.Errorf("overlay %s: %v", overlay, err)
+		}
+
+		if _, ok := patch.([]interface{}); !ok {
+			app = mergePatch(app, patch)
+			continue
+		}
+		var ops []patchOperation
+		if err = json.Unmarshal(rendered, &ops); err != nil {
+			return nil, fmt.Errorf("overlay %s: invalid JSON Patch: %v", overlay, err)
+		}
+		for i, op := range ops {
+			if app, err = applyOperation(app, op); err != nil {
+				return nil, fmt.Errorf(
+					"overlay %s: operation %d (%s) failed: %v",
+					overlay,
+					i+1,
+					op,
+					err,
+				)
+			}
+		}
+	}
+	return encodeDefinition(app)
+}
+
+// mergePatch applies an RFC 7386 JSON Merge Patch. Objects are merged, a
+// null removes the member and anything else replaces the target.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// applyOperation applies one operation of an RFC 6902 JSON Patch and returns
+// the patched document.
+func applyOperation(doc interface{}, op patchOperation) (interface{}, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("it has no path")
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("it has no value")
+		}
+		if err = decodeDefinition(op.Value, &value); err != nil {
+			return nil, err
+		}
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("it has no from")
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = getPointer(doc, from); err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			value = copyJSON(value)
+			break
+		}
+		if len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+			return nil, fmt.Errorf("%s can't be moved into one of its children", *op.From)
+		}
+		if doc, err = removePointer(doc, from); err != nil {
+			return nil, err
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("unknown op %q", op.Op)
+	}
+
+	switch op.Op {
+	case "remove":
+		return removePointer(doc, path)
+	case "replace":
+		if len(path) == 0 {
+			return value, nil
+		}
+		if _, err = getPointer(doc, path); err != nil {
+			return nil, err
+		}
+		if doc, err = removePointer(doc, path); err != nil {
+			return nil, err
+		}
+		return addPointer(doc, path, value)
+	case "test":
+		current, err := getPointer(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equalJSON(current, value) {
+			got, _ := json.Marshal(current)
+			want, _ := json.Marshal(value)
+			return nil, fmt.Errorf("the value is %s, not %s", got, want)
+		}
+		return doc, nil
+	}
+	return addPointer(doc, path, value)
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid path %q, it must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// getPointer returns the value the tokens point at.
+func getPointer(doc interface{}, tokens []string) (interface{}, error) {
+	for i, t := range tokens {
+		switch c := doc.(type) {
+		case map[string]interface{}:
+			v, ok := c[t]
+			if !ok {
+				return nil, fmt.Errorf("%s does not exist", pointerString(tokens[:i+1]))
+			}
+			doc = v
+		case []interface{}:
+			n, err := arrayIndex(t, len(c), false)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", pointerString(tokens[:i+1]), err)
+			}
+			doc = c[n]
+		default:
+			return nil, fmt.Errorf("%s is not an object or array", pointerString(tokens[:i]))
+		}
+	}
+	return doc, nil
+}
+
+// addPointer adds value at the tokens. A member of an object is set, an
+// array gets the value inserted.
+func addPointer(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return updateParent(doc, tokens, func(parent interface{}, key string) (interface{}, error) {
+		switch c := parent.(type) {
+		case map[string]interface{}:
+			c[key] = value
+			return c, nil
+		case []interface{}:
+			n, err := arrayIndex(key, len(c), true)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", pointerString(tokens), err)
+			}
+			c = append(c, nil)
+			copy(c[n+1:], c[n:])
+			c[n] = value
+			return c, nil
+		}
+		return nil, fmt.Errorf("%s is not an object or array", pointerString(tokens[:len(tokens)-1]))
+	})
+}
+
+// removePointer removes the value at the tokens.
+func removePointer(doc interface{}, tokens []string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("the whole document can't be removed")
+	}
+	return updateParent(doc, tokens, func(parent interface{}, key string) (interface{}, error) {
+		switch c := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := c[key]; !ok {
+				return nil, fmt.Errorf("%s does not exist", pointerString(tokens))
+			}
+			delete(c, key)
+			return c, nil
+		case []interface{}:
+			n, err := arrayIndex(key, len(c), false)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", pointerString(tokens), err)
+			}
+			return append(c[:n], c[n+1:]...), nil
+		}
+		return nil, fmt.Errorf("%s is not an object or array", pointerString(tokens[:len(tokens)-1]))
+	})
+}
+
+// updateParent calls f with the container holding the last token and
+// returns the document with the container replaced by what f returns, since
+// changing an array's length makes a new slice.
+func updateParent(
+	doc interface{},
+	tokens []string,
+	f func(parent interface{}, key string) (interface{}, error),
+) (interface{}, error) {
+	parentTokens := tokens[:len(tokens)-1]
+	parent, err := getPointer(doc, parentTokens)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := f(parent, tokens[len(tokens)-1])
+	if err != nil {
+		return nil, err
+	}
+	if len(parentTokens) == 0 {
+		return updated, nil
+	}
+	grandparent, _ := getPointer(doc, parentTokens[:len(parentTokens)-1])
+	key := parentTokens[len(parentTokens)-1]
+	switch c := grandparent.(type) {
+	case map[string]interface{}:
+		c[key] = updated
+	case []interface{}:
+		n, _ := arrayIndex(key, len(c), false)
+		c[n] = updated
+	}
+	return doc, nil
+}
+
+// arrayIndex parses an array index token. "-", the end of the array, is only
+// allowed when adding.
+func arrayIndex(token string, length int, adding bool) (int, error) {
+	if token == "-" && adding {
+		return length, nil
+	}
+	n, err := strconv.Atoi(token)
+	if err != nil || n < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if n > length || (n == length && !adding) {
+		return 0, fmt.Errorf("index %d is out of range", n)
+	}
+	return n, nil
+}
+
+func pointerString(tokens []string) string {
+	if len(tokens) == 0 {
+		return "the document"
+	}
+	var b bytes.Buffer
+	for _, t := range tokens {
+		b.WriteByte('/')
+		b.WriteString(strings.Replace(strings.Replace(t, "~", "~0", -1), "/", "~1", -1))
+	}
+	return b.String()
+}
+
+// copyJSON deep copies a decoded JSON value.
+func copyJSON(v interface{}) interface{} {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(c))
+		for k, e := range c {
+			m[k] = copyJSON(e)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(c))
+		for i, e := range c {
+			a[i] = copyJSON(e)
+		}
+		return a
+	}
+	return v
+}
+
+// equalJSON compares decoded JSON values. Numbers are equal when their values
+// are, however they are written.
+func equalJSON(a, b interface{}) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !equalJSON(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equalJSON(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
@@ -0,0 +1,151 @@
+package behaviors
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_mergePatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		patch  string
+		want   string
+	}{
+		{"Replaces a member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"Adds a member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"Removes a member", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"Replaces an array", `{"a":["b"]}`, `{"a":["c","d"]}`, `{"a":["c","d"]}`},
+		{"Merges nested objects", `{"a":{"b":"c","d":1}}`, `{"a":{"b":"e","f":null}}`, `{"a":{"b":"e","d":1}}`},
+		{"Replaces a non-object", `{"a":"b"}`, `{"a":{"c":null,"d":2}}`, `{"a":{"d":2}}`},
+		{"Replaces the document", `{"a":"b"}`, `["c"]`, `["c"]`},
+	}
+	for _, tt := range tests {
+		var target, patch interface{}
+		decodeDefinition([]byte(tt.target), &target)
+		decodeDefinition([]byte(tt.patch), &patch)
+		got, _ := json.Marshal(mergePatch(target, patch))
+		if string(got) != tt.want {
+			t.Errorf("%q. mergePatch() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_applyOperation(t *testing.T) {
+	doc := `{"id":"/foo","instances":1,"args":["a","b"],"labels":{"a/b":"c","d~e":"f"}}`
+	tests := []struct {
+		name    string
+		op      string
+		want    string
+		wantErr bool
+	}{
+		{
+			"Add a member",
+			`{"op":"add","path":"/cpus","value":0.5}`,
+			`{"args":["a","b"],"cpus":0.5,"id":"/foo","instances":1,"labels":{"a/b":"c","d~e":"f"}}`,
+			false,
+		},
+		{
+			"Add to an array",
+			`{"op":"add","path":"/args/1","value":"x"}`,
+			`{"args":["a","x","b"],"id":"/foo","instances":1,"labels":{"a/b":"c","d~e":"f"}}`,
+			false,
+		},
+		{
+			"Add to the end of an array",
+			`{"op":"add","path":"/args/-","value":"x"}`,
+			`{"args":["a","b","x"],"id":"/foo","instances":1,"labels":{"a/b":"c","d~e":"f"}}`,
+			false,
+		},
+		{
+			"Remove an escaped member",
+			`{"op":"remove","path":"/labels/a~1b"}`,
+			`{"args":["a","b"],"id":"/foo","instances":1,"labels":{"d~e":"f"}}`,
+			false,
+		},
+		{
+			"Remove from an array",
+			`{"op":"remove","path":"/args/0"}`,
+			`{"args":["b"],"id":"/foo","instances":1,"labels":{"a/b":"c","d~e":"f"}}`,
+			false,
+		},
+		{
+			"Replace",
+			`{"op":"replace","path":"/labels/d~0e","value":null}`,
+			`{"args":["a","b"],"id":"/foo","instances":1,"labels":{"a/b":"c","d~e":null}}`,
+			false,
+		},
+		{
+			"Move",
+			`{"op":"move","from":"/args","path":"/labels/args"}`,
+			`{"id":"/foo","instances":1,"labels":{"a/b":"c","args":["a","b"],"d~e":"f"}}`,
+			false,
+		},
+		{
+			"Copy",
+			`{"op":"copy","from":"/args/1","path":"/args/0"}`,
+			`{"args":["b","a","b"],"id":"/foo","instances":1,"labels":{"a/b":"c","d~e":"f"}}`,
+			false,
+		},
+		{
+			"Test",
+			`{"op":"test","path":"/instances","value":1.0}`,
+			`{"args":["a","b"],"id":"/foo","instances":1,"labels":{"a/b":"c","d~e":"f"}}`,
+			false,
+		},
+		{"Test fails", `{"op":"test","path":"/instances","value":2}`, "", true},
+		{"Replace a missing member", `{"op":"replace","path":"/cpus","value":1}`, "", true},
+		{"Remove a missing member", `{"op":"remove","path":"/container/type"}`, "", true},
+		{"Add past the end of an array", `{"op":"add","path":"/args/3","value":"x"}`, "", true},
+		{"Invalid index", `{"op":"remove","path":"/args/01"}`, "", true},
+		{"Move into a child", `{"op":"move","from":"/labels","path":"/labels/x"}`, "", true},
+		{"Invalid path", `{"op":"add","path":"cpus","value":1}`, "", true},
+		{"No value", `{"op":"add","path":"/cpus"}`, "", true},
+		{"Unknown op", `{"op":"merge","path":"/cpus"}`, "", true},
+	}
+	for _, tt := range tests {
+		var d interface{}
+		decodeDefinition([]byte(doc), &d)
+		var op patchOperation
+		if err := json.Unmarshal([]byte(tt.op), &op); err != nil {
+			t.Fatal(err)
+		}
+		got, err := applyOperation(d, op)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. applyOperation() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if j, _ := json.Marshal(got); string(j) != tt.want {
+			t.Errorf("%q. applyOperation() = %s, want %s", tt.name, j, tt.want)
+		}
+	}
+}
+
+func Test_applyOverlays_error(t *testing.T) {
+	_, err := applyOverlays(
+		[]byte(`{"id":"/foo"}`),
+		[]string{"overlay_bad.json"},
+		"../fixtures",
+		map[string]string{},
+	)
+	want := "overlay overlay_bad.json: operation 2 (replace /container/docker/image) failed: /container does not exist"
+	if err == nil || err.Error() != want {
+		t.Errorf("applyOverlays() error = %v, want %v", err, want)
+	}
+}
+
+func Test_applyOverlays_keepsValues(t *testing.T) {
+	got, err := applyOverlays(
+		[]byte(`{"cmd":"a && b <c>","mem":1.50}`),
+		[]string{"overlay_merge.json"},
+		"../fixtures",
+		map[string]string{"foo": "bar"},
+	)
+	want := `{"cmd":"a && b <c>","foo":"bar","instances":2,"mem":1.50}`
+	if err != nil || string(got) != want {
+		t.Errorf("applyOverlays() = %s, %v, want %s", got, err, want)
+	}
+}
@@ -0,0 +1,309 @@
+package behaviors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+)
+
+// releaseApp is one of the apps deployed together when `app_json` names
+// several files.
+type releaseApp struct {
+	id   string
+	file string
+	json []byte
+	// dependencies are the normalized IDs of the apps this one depends on.
+	dependencies []string
+	// previous is the version running before the put, empty for a new app.
+	previous string
+}
+
+// deployRelease deploys several apps. Apps are deployed in layers so an app
+// is only deployed once the apps it depends on are. The apps in a layer are
+// deployed in parallel. If any of them fails every app deployed so far is
+// rolled back.
+func deployRelease(
+	input InputJSON,
+	files []string,
+	dir string,
+	apiclient marathon.Marathoner,
+) (IOOutput, error) {
+	switch {
+	case input.Params.DryRun:
+		return IOOutput{}, fmt.Errorf("dry_run needs a single app_json file")
+	case input.Params.Strategy != strategyRolling:
+		return IOOutput{}, fmt.Errorf(
+			"strategy %s needs a single app_json file",
+			input.Params.Strategy,
+		)
+	}
+
+	apps, err := loadRelease(input, files, dir)
+	if err != nil {
+		return IOOutput{}, err
+	}
+	sourceID := normalizeID(input.Source.AppID)
+	if !releaseHas(apps, sourceID) {
+		return IOOutput{}, fmt.Errorf(
+			"app_id %s is not one of the apps in app_json, it's needed for the version",
+			sourceID,
+		)
+	}
+	layers, err := releaseLayers(apps)
+	if err != nil {
+		return IOOutput{}, err
+	}
+	for _, app := range apps {
+		if app.previous, err = runningVersion(app.id, apiclient); err != nil {
+			return IOOutput{}, err
+		}
+	}
+
+	var (
+		deployed []*releaseApp
+		version  string
+		metadata []Metadata
+	)
+	for _, layer := range layers {
+		deployed = append(deployed, layer...)
+		outputs, err := deployLayer(input, layer, apiclient)
+		if err != nil {
+			return IOOutput{}, rollbackRelease(err, deployed, input.Params, apiclient)
+		}
+		for i, app := range layer {
+			if app.id == sourceID {
+				version = outputs[i].Version.Ref
+			}
+			metadata = append(metadata, Metadata{app.id, outputs[i].Version.Ref})
+		}
+	}
+
+	// The version is that of the app in `app_id` so `in` and `check` can
+	// find it.
+	return IOOutput{Version: Version{Ref: version}, Metadata: metadata}, nil
+}
+
+// releaseHas reports whether the app with the given ID is in the release.
+func releaseHas(apps []*releaseApp, id string) bool {
+	for _, app := range apps {
+		if app.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// loadRelease renders the files and reads the ID and dependencies of each
+// app.
+func loadRelease(
+	input InputJSON,
+	files []string,
+	dir string,
+) ([]*releaseApp, error) {
+	var (
+		apps []*releaseApp
+		seen = map[string]string{}
+	)
+	for _, file := range files {
+		jsondata, err := parsePayload(input.Params, dir, file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		appJSON, err := ioutil.ReadAll(jsondata)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+
+		var app struct {
+			definition
+			Dependencies []string `json:"dependencies"`
+		}
+		if err = json.Unmarshal(appJSON, &app); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		if kind := app.kind(input.Source.Kind); kind != kindApp {
+			return nil, fmt.Errorf("%s: only apps can be deployed together, not %ss", file, kind)
+		}
+		if app.ID == "" {
+			return nil, fmt.Errorf("%s: the app has no id", file)
+		}
+
+		id := normalizeID(app.ID)
+		if other, ok := seen[id]; ok {
+			return nil, fmt.Errorf("%s and %s both define %s", other, file, id)
+		}
+		seen[id] = file
+
+		r := &releaseApp{id: id, file: file, json: appJSON}
+		for _, dep := range app.Dependencies {
+			r.dependencies = append(r.dependencies, resolveDependency(id, dep))
+		}
+		apps = append(apps, r)
+	}
+	return apps, nil
+}
+
+// resolveDependency returns the absolute ID of a dependency. Relative ones
+// are relative to the app's group the same as in Marathon.
+func resolveDependency(id, dep string) string {
+	if strings.HasPrefix(dep, "/") {
+		return normalizeID(dep)
+	}
+	return normalizeID(path.Join(path.Dir(id), dep))
+}
+
+// releaseLayers orders the apps so each layer only depends on the layers
+// before it. Dependencies on apps outside of the release are left to
+// Marathon.
+func releaseLayers(apps []*releaseApp) ([][]*releaseApp, error) {
+	var (
+		layers    [][]*releaseApp
+		inRelease = map[string]bool{}
+		done      = map[string]bool{}
+	)
+	for _, app := range apps {
+		inRelease[app.id] = true
+	}
+
+	for len(done) < len(apps) {
+		var layer []*releaseApp
+		for _, app := range apps {
+			if done[app.id] {
+				continue
+			}
+			ready := true
+			for _, dep := range app.dependencies {
+				if inRelease[dep] && !done[dep] {
+					ready = false
+				}
+			}
+			if ready {
+				layer = append(layer, app)
+			}
+		}
+
+		if len(layer) == 0 {
+			var cycle []string
+			for _, app := range apps {
+				if !done[app.id] {
+					cycle = append(cycle, app.id)
+				}
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf(
+				"The dependencies of %s form a cycle",
+				strings.Join(cycle, ", "),
+			)
+		}
+		for _, app := range layer {
+			done[app.id] = true
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+// runningVersion returns the version of the running app or an empty string
+// when it doesn't exist.
+func runningVersion(id string, apiclient marathon.Marathoner) (string, error) {
+	versions, err := apiclient.LatestVersions(id, "")
+	if apiErr, ok := err.(*marathon.APIError); ok && apiErr.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return versions[len(versions)-1], nil
+}
+
+// deployLayer deploys the apps of a layer in parallel. Every failure is
+// logged and the first one returned.
+func deployLayer(
+	input InputJSON,
+	layer []*releaseApp,
+	apiclient marathon.Marathoner,
+) ([]IOOutput, error) {
+	var (
+		outputs = make([]IOOutput, len(layer))
+		errs    = make([]error, len(layer))
+		wg      sync.WaitGroup
+	)
+	for i, app := range layer {
+		wg.Add(1)
+		go func(i int, app *releaseApp) {
+			defer wg.Done()
+			outputs[i], errs[i] = deployApp(input, app.id, app.json, apiclient)
+		}(i, app)
+	}
+	wg.Wait()
+
+	var first error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		fmt.Fprintf(buildLog, "Deploying %s from %s failed: %v\n", layer[i].id, layer[i].file, err)
+		if first == nil {
+			first = err
+		}
+	}
+	return outputs, first
+}
+
+// rollbackRelease puts every app that changed back to the version it had
+// before the put, dependents first. Apps the put created are removed.
+func rollbackRelease(
+	err error,
+	apps []*releaseApp,
+	params Params,
+	apiclient marathon.Marathoner,
+) error {
+	var cleanup []string
+	for i := len(apps) - 1; i >= 0; i-- {
+		app := apps[i]
+		current, rerr := runningVersion(app.id, apiclient)
+		if rerr != nil {
+			cleanup = append(cleanup, fmt.Sprintf("could not check %s: %v", app.id, rerr))
+			continue
+		}
+		if current == app.previous {
+			continue
+		}
+
+		restored, rerr := restoreApp(app, params, apiclient)
+		if rerr != nil {
+			cleanup = append(cleanup, fmt.Sprintf("could not roll back %s: %v", app.id, rerr))
+			continue
+		}
+		cleanup = append(cleanup, restored)
+	}
+	if len(cleanup) == 0 {
+		cleanup = []string{"no apps had changed"}
+	}
+	return withCleanup(err, cleanup)
+}
+
+// restoreApp rolls an app back to its previous version, or removes it if it
+// is new, and says what it did.
+func restoreApp(
+	app *releaseApp,
+	params Params,
+	apiclient marathon.Marathoner,
+) (string, error) {
+	if app.previous == "" {
+		return fmt.Sprintf("removed %s", app.id), removeApp(app.id, params, apiclient)
+	}
+	did, err := apiclient.RollbackApp(app.id, app.previous)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("rolled back %s to %s", app.id, app.previous),
+		waitCleanup(did.DeploymentID, params, apiclient)
+}
@@ -0,0 +1,210 @@
+package behaviors
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/marathon"
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
+	gomarathon "github.com/gambol99/go-marathon"
+	"github.com/golang/mock/gomock"
+)
+
+func Test_releaseLayers(t *testing.T) {
+	app := func(id string, deps ...string) *releaseApp {
+		r := &releaseApp{id: id}
+		for _, dep := range deps {
+			r.dependencies = append(r.dependencies, resolveDependency(id, dep))
+		}
+		return r
+	}
+	ids := func(layers [][]*releaseApp) [][]string {
+		var out [][]string
+		for _, layer := range layers {
+			var l []string
+			for _, a := range layer {
+				l = append(l, a.id)
+			}
+			out = append(out, l)
+		}
+		return out
+	}
+	tests := []struct {
+		name    string
+		apps    []*releaseApp
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			"Independent",
+			[]*releaseApp{app("/a"), app("/b")},
+			[][]string{{"/a", "/b"}},
+			false,
+		},
+		{
+			"Chain",
+			[]*releaseApp{app("/web", "/api"), app("/api", "/db"), app("/db")},
+			[][]string{{"/db"}, {"/api"}, {"/web"}},
+			false,
+		},
+		{
+			"Relative and outside dependencies",
+			[]*releaseApp{app("/prod/web", "../shared/cache", "db"), app("/prod/db"), app("/prod/worker", "db")},
+			[][]string{{"/prod/db"}, {"/prod/web", "/prod/worker"}},
+			false,
+		},
+		{
+			"Cycle",
+			[]*releaseApp{app("/a", "/b"), app("/b", "/a"), app("/c")},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := releaseLayers(tt.apps)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. releaseLayers() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(ids(got), tt.want) {
+			t.Errorf("%q. releaseLayers() = %v, want %v", tt.name, ids(got), tt.want)
+		}
+	}
+}
+
+func TestOut_release(t *testing.T) {
+	const (
+		v1 = "2017-01-01T00:00:00Z"
+		v2 = "2017-02-01T00:00:00Z"
+		v3 = "2017-02-01T00:00:05Z"
+	)
+	dir, err := ioutil.TempDir("", "marathon-resource-release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = os.Mkdir(filepath.Join(dir, "apps"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for file, app := range map[string]string{
+		"apps/api.json": `{"id":"/shop/api","dependencies":["db"],"env":{"TAG":"{{tag}}"}}`,
+		"apps/db.json":  `{"id":"/shop/db"}`,
+		"web.json":      `{"id":"/shop/web","dependencies":["/shop/api"]}`,
+	} {
+		if err = ioutil.WriteFile(filepath.Join(dir, file), []byte(app), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notFound := &marathon.APIError{StatusCode: http.StatusNotFound}
+
+	tests := []struct {
+		name    string
+		appID   string
+		expect  func(m *mocks.MockMarathoner)
+		want    IOOutput
+		wantErr string
+	}{
+		{
+			"Deploys in order",
+			"shop/api",
+			func(m *mocks.MockMarathoner) {
+				db := m.EXPECT().UpdateApp("/shop/db", gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "db", Version: v2}, nil)
+				api := m.EXPECT().UpdateApp("/shop/api", gomock.Any(), false).Times(1).Do(func(_ string, appJSON []byte, _ bool) {
+					if !bytes.Contains(appJSON, []byte(`"TAG":"1.2.3"`)) {
+						t.Errorf("Out() deployed %s without rendering it", appJSON)
+					}
+				}).Return(gomarathon.DeploymentID{DeploymentID: "api", Version: v2}, nil)
+				web := m.EXPECT().UpdateApp("/shop/web", gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "web", Version: v3}, nil)
+				gomock.InOrder(
+					m.EXPECT().LatestVersions("/shop/api", "").Times(1).Return([]string{v1}, nil),
+					m.EXPECT().LatestVersions("/shop/db", "").Times(1).Return([]string{v1}, nil),
+					m.EXPECT().LatestVersions("/shop/web", "").Times(1).Return(nil, notFound),
+					db,
+					m.EXPECT().WaitDeployment("db", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().LatestVersions("/shop/db", "").Times(1).Return([]string{v1, v2}, nil),
+					api,
+					m.EXPECT().WaitDeployment("api", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().LatestVersions("/shop/api", "").Times(1).Return([]string{v1, v2}, nil),
+					web,
+					m.EXPECT().WaitDeployment("web", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().LatestVersions("/shop/web", "").Times(1).Return([]string{v3}, nil),
+				)
+			},
+			IOOutput{
+				Version:  Version{Ref: v2},
+				Metadata: []Metadata{{"/shop/db", v2}, {"/shop/api", v2}, {"/shop/web", v3}},
+			},
+			"",
+		},
+		{
+			"Rolls back when a layer fails",
+			"/shop/web",
+			func(m *mocks.MockMarathoner) {
+				gomock.InOrder(
+					m.EXPECT().LatestVersions("/shop/api", "").Times(1).Return([]string{v1}, nil),
+					m.EXPECT().LatestVersions("/shop/db", "").Times(1).Return([]string{v1}, nil),
+					m.EXPECT().LatestVersions("/shop/web", "").Times(1).Return(nil, notFound),
+					m.EXPECT().UpdateApp("/shop/db", gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "db", Version: v2}, nil),
+					m.EXPECT().WaitDeployment("db", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().LatestVersions("/shop/db", "").Times(1).Return([]string{v1, v2}, nil),
+					m.EXPECT().UpdateApp("/shop/api", gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "api", Version: v2}, nil),
+					m.EXPECT().WaitDeployment("api", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().LatestVersions("/shop/api", "").Times(1).Return([]string{v1, v2}, nil),
+					m.EXPECT().UpdateApp("/shop/web", gomock.Any(), false).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "web", Version: v3}, nil),
+					m.EXPECT().WaitDeployment("web", gomock.Any()).Times(1).Return(false, marathon.ErrDeploymentFailed),
+					m.EXPECT().LatestVersions("/shop/web", "").Times(1).Return([]string{v3}, nil),
+					m.EXPECT().DeleteApp("/shop/web").Times(1).Return(gomarathon.DeploymentID{DeploymentID: "rm-web"}, nil),
+					m.EXPECT().WaitDeployment("rm-web", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().LatestVersions("/shop/api", "").Times(1).Return([]string{v1, v2}, nil),
+					m.EXPECT().RollbackApp("/shop/api", v1).Times(1).Return(gomarathon.DeploymentID{DeploymentID: "rb-api"}, nil),
+					m.EXPECT().WaitDeployment("rb-api", gomock.Any()).Times(1).Return(false, nil),
+					m.EXPECT().LatestVersions("/shop/db", "").Times(1).Return([]string{v1, v2}, nil),
+					m.EXPECT().RollbackApp("/shop/db", v1).Times(1).Return(gomarathon.DeploymentID{}, errors.New("Bad stuff")),
+				)
+			},
+			IOOutput{},
+			"Deployment failed; removed /shop/web; rolled back /shop/api to " + v1 + "; could not roll back /shop/db: Bad stuff",
+		},
+		{
+			"App ID not in the release",
+			"/shop/cart",
+			func(m *mocks.MockMarathoner) {},
+			IOOutput{},
+			"app_id /shop/cart is not one of the apps in app_json",
+		},
+	}
+	for _, tt := range tests {
+		ctrl := gomock.NewController(t)
+		mockMarathoner := mocks.NewMockMarathoner(ctrl)
+		tt.expect(mockMarathoner)
+		cleanup := allowDiagnostics(t, mockMarathoner)
+
+		input := InputJSON{
+			Params: Params{
+				AppJSON:      AppJSONPaths{"apps/*.json", "web.json", "apps/db.json"},
+				TimeOut:      2,
+				Replacements: []Metadata{{"tag", "1.2.3"}},
+			},
+			Source: Source{AppID: tt.appID},
+		}
+		got, err := Out(input, dir, mockMarathoner)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%q. Out() error = %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%q. Out() error = %v, want %q", tt.name, err, tt.wantErr)
+		case !reflect.DeepEqual(got, tt.want):
+			t.Errorf("%q. Out() = %v, want %v", tt.name, got, tt.want)
+		}
+		cleanup()
+		ctrl.Finish()
+	}
+}
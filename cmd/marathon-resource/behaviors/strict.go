@@ -0,0 +1,175 @@
+package behaviors
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aymerick/raymond/ast"
+	"github.com/aymerick/raymond/parser"
+)
+
+// builtinHelpers are the helpers raymond registers. Their names aren't
+// replacements.
+var builtinHelpers = map[string]bool{
+	"if":     true,
+	"unless": true,
+	"with":   true,
+	"each":   true,
+	"log":    true,
+	"lookup": true,
+}
+
+// checkStrict checks every template of a put when `strict_templates` is set.
+// The `app_json` files and overlays are checked together so a replacement
+// only one of the apps of a release uses isn't reported as unused.
+func checkStrict(p Params, path string, files []string) error {
+	if !p.StrictTemplates {
+		return nil
+	}
+	replacements, err := templateReplacements(p, path)
+	if err != nil {
+		return err
+	}
+	templates := append(append([]string{}, files...), p.Overlays...)
+	return checkTemplates(templates, path, replacements)
+}
+
+// checkTemplates fails when the templates use replacements that aren't set,
+// which would otherwise render as empty strings. Replacements none of the
+// templates use are only warned about.
+func checkTemplates(
+	files []string,
+	path string,
+	replacements map[string]string,
+) error {
+	var (
+		missing []string
+		used    = map[string]bool{}
+	)
+	for _, file := range files {
+		source, err := ioutil.ReadFile(filepath.Join(path, file))
+		if err != nil {
+			return err
+		}
+		program, err := parser.Parse(string(source))
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+
+		refs := templateRefs{lines: map[string]int{}}
+		refs.walk(program)
+
+		var names []string
+		for name := range refs.lines {
+			used[name] = true
+			if _, ok := replacements[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			missing = append(missing, fmt.Sprintf("%s (%s line %d)", name, file, refs.lines[name]))
+		}
+	}
+
+	var unused []string
+	for name := range replacements {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		fmt.Fprintf(
+			buildLog,
+			"Warning: replacements not used by %s: %s\n",
+			strings.Join(files, ", "),
+			strings.Join(unused, ", "),
+		)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf(
+			"strict_templates: replacements used but not set: %s",
+			strings.Join(missing, ", "),
+		)
+	}
+	return nil
+}
+
+// templateRefs collects the replacements a template uses and the line each
+// is first used on.
+type templateRefs struct {
+	lines map[string]int
+	// depth is how many blocks that change the context, like `each` and
+	// `with`, the walk is in. Only paths back at the top context are
+	// replacements.
+	depth int
+}
+
+func (r *templateRefs) walk(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.Program:
+		for _, statement := range n.Body {
+			r.walk(statement)
+		}
+	case *ast.MustacheStatement:
+		r.expression(n.Expression)
+	case *ast.BlockStatement:
+		r.expression(n.Expression)
+		switch n.Expression.HelperName() {
+		case "if", "unless":
+			r.walkProgram(n.Program)
+		default:
+			r.depth++
+			r.walkProgram(n.Program)
+			r.depth--
+		}
+		r.walkProgram(n.Inverse)
+	case *ast.PartialStatement:
+		for _, param := range n.Params {
+			r.walk(param)
+		}
+		r.walkHash(n.Hash)
+	case *ast.Expression:
+		r.expression(n)
+	case *ast.SubExpression:
+		r.expression(n.Expression)
+	case *ast.PathExpression:
+		if !n.Data && n.Depth == r.depth && len(n.Parts) > 0 {
+			if _, ok := r.lines[n.Parts[0]]; !ok {
+				r.lines[n.Parts[0]] = n.Line
+			}
+		}
+	}
+}
+
+func (r *templateRefs) walkProgram(program *ast.Program) {
+	if program != nil {
+		r.walk(program)
+	}
+}
+
+func (r *templateRefs) walkHash(hash *ast.Hash) {
+	if hash == nil {
+		return
+	}
+	for _, pair := range hash.Pairs {
+		r.walk(pair.Val)
+	}
+}
+
+// expression walks an expression. Its path is a helper rather than a
+// replacement when it names one.
+func (r *templateRefs) expression(e *ast.Expression) {
+	if !builtinHelpers[e.HelperName()] {
+		r.walk(e.Path)
+	}
+	for _, param := range e.Params {
+		r.walk(param)
+	}
+	r.walkHash(e.Hash)
+}
@@ -0,0 +1,158 @@
+package behaviors
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_checkTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "marathon-resource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := &bytes.Buffer{}
+	defer func(w io.Writer) { buildLog = w }(buildLog)
+	buildLog = out
+
+	tests := []struct {
+		name         string
+		template     string
+		replacements map[string]string
+		wantErr      string
+		wantLog      string
+	}{
+		{
+			"All set",
+			`{"id": "{{a}}"{{#if b}}, "cmd": "{{{c}}}"{{/if}} }`,
+			map[string]string{"a": "", "b": "", "c": ""},
+			"",
+			"",
+		},
+		{
+			"Missing",
+			"{\n\"id\": \"{{a}}\",\n\"env\": {\"PASSWORD\": \"{{db_password}}\"},\n{{#if (lookup x \"y\")}}{{/if}}\n}",
+			map[string]string{"a": ""},
+			"strict_templates: replacements used but not set: db_password (app.json line 3), x (app.json line 4)",
+			"",
+		},
+		{
+			"Else of a helper",
+			`{{#unless a}}x{{else}}{{b}}{{/unless}}`,
+			map[string]string{"a": ""},
+			"strict_templates: replacements used but not set: b (app.json line 1)",
+			"",
+		},
+		{
+			"Names in a changed context are not replacements",
+			`{{#each list}}{{name}} {{../a}} {{@index}}{{/each}}{{#with obj}}{{other}}{{/with}}`,
+			map[string]string{"list": "", "a": "", "obj": ""},
+			"",
+			"",
+		},
+		{
+			"Unused",
+			`{{a}}`,
+			map[string]string{"a": "", "c": "", "b": ""},
+			"",
+			"Warning: replacements not used by app.json: b, c\n",
+		},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		if err = ioutil.WriteFile(filepath.Join(dir, "app.json"), []byte(tt.template), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := checkTemplates([]string{"app.json"}, dir, tt.replacements)
+		if (err != nil) != (tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
+			t.Errorf("%q. checkTemplates() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if out.String() != tt.wantLog {
+			t.Errorf("%q. checkTemplates() logged %q, want %q", tt.name, out.String(), tt.wantLog)
+		}
+	}
+}
+
+func Test_checkStrict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "marathon-resource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for file, template := range map[string]string{
+		"api.json": `{"id": "/api", "env": {"TAG": "{{tag}}", "DB": "{{db_url}}"}}`,
+		"web.json": `{"id": "/web", "env": {"TAG": "{{tag}}", "API": "{{api_url}}"}}`,
+	} {
+		if err = ioutil.WriteFile(filepath.Join(dir, file), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	defer func(w io.Writer) { buildLog = w }(buildLog)
+	buildLog = out
+
+	set := func(names ...string) []Metadata {
+		var m []Metadata
+		for _, name := range names {
+			m = append(m, Metadata{name, ""})
+		}
+		return m
+	}
+	tests := []struct {
+		name    string
+		params  Params
+		files   []string
+		wantErr string
+		wantLog string
+	}{
+		{"Not strict", Params{}, []string{"api.json"}, "", ""},
+		{
+			"Replacements used by different apps",
+			Params{StrictTemplates: true, Replacements: set("tag", "db_url", "api_url")},
+			[]string{"api.json", "web.json"},
+			"",
+			"",
+		},
+		{
+			"Missing per file",
+			Params{StrictTemplates: true, Replacements: set("tag")},
+			[]string{"api.json", "web.json"},
+			"strict_templates: replacements used but not set: db_url (api.json line 1), api_url (web.json line 1)",
+			"",
+		},
+		{
+			"Unused by all files",
+			Params{StrictTemplates: true, Replacements: set("tag", "db_url", "api_url", "cdn")},
+			[]string{"api.json", "web.json"},
+			"",
+			"Warning: replacements not used by api.json, web.json: cdn\n",
+		},
+		{
+			"Overlays are checked",
+			Params{StrictTemplates: true, Overlays: []string{"overlay_merge.json"}},
+			[]string{"app.json"},
+			"strict_templates: replacements used but not set: foo (overlay_merge.json line 2)",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		path := dir
+		if len(tt.params.Overlays) > 0 {
+			path = "../fixtures"
+		}
+		err := checkStrict(tt.params, path, tt.files)
+		if (err != nil) != (tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
+			t.Errorf("%q. checkStrict() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if out.String() != tt.wantLog {
+			t.Errorf("%q. checkStrict() logged %q, want %q", tt.name, out.String(), tt.wantLog)
+		}
+	}
+}
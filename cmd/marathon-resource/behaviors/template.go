@@ -2,27 +2,62 @@ package behaviors
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/aymerick/raymond"
 )
 
-func parsePayload(p Params, path string) (io.Reader, error) {
-	var (
-		replacements = map[string]string{}
-		buf          = bytes.NewBuffer([]byte{})
-	)
-	replacements = replaceStrings(p.Replacements, replacements)
-	replacements, err := replaceFiles(p.ReplacementFiles, replacements, path)
+// lineMarker tags the lines of a YAML template with their line numbers so
+// errors in the rendered YAML can point at the template. YAML doesn't allow
+// the control character in a document so it can't clash with one.
+var lineMarker = regexp.MustCompile("\x1f([0-9]+)\x1f")
+
+// yamlErrorLine finds the line numbers in yaml.v2's errors.
+var yamlErrorLine = regexp.MustCompile(`line ([0-9]+)`)
+
+// standaloneTag matches the lines Handlebars removes from the output, like
+// `{{#if}}` on its own. Marking them would keep them.
+var standaloneTag = regexp.MustCompile(`^\s*\{\{~?\s*([#/^!>]|else\b).*\}\}\s*$`)
+
+func parsePayload(p Params, path string, file string) (io.Reader, error) {
+	replacements, err := templateReplacements(p, path)
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := renderFile(filepath.Join(path, file), replacements)
 	if err != nil {
 		return nil, err
 	}
+	if len(p.Overlays) > 0 {
+		if app, err = applyOverlays(app, p.Overlays, path, replacements); err != nil {
+			return nil, err
+		}
+	}
+	return bytes.NewBuffer(app), nil
+}
+
+// templateReplacements returns the `replacements` and `replacement_files`
+// the templates are rendered with.
+func templateReplacements(p Params, path string) (map[string]string, error) {
+	replacements := replaceStrings(p.Replacements, map[string]string{})
+	return replaceFiles(p.ReplacementFiles, replacements, path)
+}
+
+// renderFile renders a template. YAML templates are converted to JSON.
+func renderFile(file string, replacements map[string]string) ([]byte, error) {
+	if isYAML(file) {
+		return renderYAML(file, replacements)
+	}
 
-	tmpl, err := raymond.ParseFile(filepath.Join(path, p.AppJSON))
+	tmpl, err := raymond.ParseFile(file)
 	if err != nil {
 		return nil, err
 	}
@@ -30,11 +65,129 @@ func parsePayload(p Params, path string) (io.Reader, error) {
 	if err != nil {
 		return nil, err
 	}
+	return []byte(app), nil
+}
 
-	if _, err = buf.WriteString(app); err != nil {
+func isYAML(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// renderYAML renders a YAML template and converts it to JSON. Errors in the
+// YAML are reported against the lines of the template.
+func renderYAML(file string, replacements map[string]string) ([]byte, error) {
+	source, err := ioutil.ReadFile(file)
+	if err != nil {
 		return nil, err
 	}
-	return buf, nil
+	tmpl, err := raymond.Parse(string(source))
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := tmpl.Exec(replacements)
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := yamlToJSON([]byte(rendered))
+	if err == nil {
+		return app, nil
+	}
+	lines := templateLines(string(source), rendered, replacements)
+	if lines == nil {
+		return nil, fmt.Errorf("%v (lines of the rendered template)", err)
+	}
+	return nil, errors.New(yamlErrorLine.ReplaceAllStringFunc(err.Error(), func(m string) string {
+		n, _ := strconv.Atoi(yamlErrorLine.FindStringSubmatch(m)[1])
+		if n < 1 || n > len(lines) {
+			return m
+		}
+		return fmt.Sprintf("line %d", lines[n-1])
+	}))
+}
+
+// templateLines returns the template line each line of the rendered output
+// came from. The template is rendered again with every line marked. Output
+// lines without a marker, like those from a multi-line replacement, belong
+// to the next marker. It returns nil if the markers changed the output.
+func templateLines(
+	source string,
+	rendered string,
+	replacements map[string]string,
+) []int {
+	var marked bytes.Buffer
+	for i, line := range strings.Split(source, "\n") {
+		if i > 0 {
+			marked.WriteByte('\n')
+		}
+		marked.WriteString(line)
+		if !standaloneTag.MatchString(line) {
+			fmt.Fprintf(&marked, "\x1f%d\x1f", i+1)
+		}
+	}
+	out, err := raymond.Render(marked.String(), replacements)
+	if err != nil || lineMarker.ReplaceAllString(out, "") != rendered {
+		return nil
+	}
+
+	var (
+		outLines = strings.Split(out, "\n")
+		lines    = make([]int, len(outLines))
+		pending  []int
+	)
+	for i, line := range outLines {
+		m := lineMarker.FindStringSubmatch(line)
+		if m == nil {
+			pending = append(pending, i)
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		for _, p := range append(pending, i) {
+			lines[p] = n
+		}
+		pending = nil
+	}
+	last := strings.Count(source, "\n") + 1
+	for _, p := range pending {
+		lines[p] = last
+	}
+	return lines
+}
+
+// appJSONFiles expands the `app_json` globs into the files they match,
+// relative to path. A path that matches nothing is kept as is so reading it
+// fails.
+func appJSONFiles(patterns AppJSONPaths, path string) ([]string, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("app_json is not set")
+	}
+	var (
+		files []string
+		seen  = map[string]bool{}
+	)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(path, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid app_json %q: %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			matches = []string{filepath.Join(path, pattern)}
+		}
+		for _, m := range matches {
+			file, err := filepath.Rel(path, m)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
 }
 
 func replaceStrings(
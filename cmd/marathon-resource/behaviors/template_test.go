@@ -2,6 +2,8 @@ package behaviors
 
 import (
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -17,14 +19,18 @@ func Test_parsePayload(t *testing.T) {
 		want    []byte
 		wantErr bool
 	}{
-		{"Reads file with no replacements", args{Params{AppJSON: "app.json"}, "../fixtures"}, []byte{123, 10, 32, 32, 32, 32, 34, 102, 111, 111, 34, 58, 32, 34, 98, 97, 114, 34, 10, 125, 10}, false},
-		{"Reads file with replacements", args{Params{AppJSON: "app_template.json", Replacements: []Metadata{{"foo", "bar"}}}, "../fixtures"}, []byte{123, 10, 32, 32, 32, 32, 34, 102, 111, 111, 34, 58, 32, 34, 98, 97, 114, 34, 10, 125, 10}, false},
-		{"Reads file with replacement files", args{Params{AppJSON: "app_template.json", ReplacementFiles: []Metadata{{"foo", "foo.txt"}}}, "../fixtures"}, []byte{123, 10, 32, 32, 32, 32, 34, 102, 111, 111, 34, 58, 32, 34, 98, 97, 114, 34, 10, 125, 10}, false},
-		{"Reads file with missing replacement files", args{Params{AppJSON: "app_template.json", ReplacementFiles: []Metadata{{"foo", "baz.txt"}}}, "../fixtures"}, nil, true},
-		{"Reads file with bad tmpl", args{Params{AppJSON: "app_template_bad.json", Replacements: []Metadata{{"foo", "bar"}}}, "../fixtures"}, nil, true},
+		{"Reads file with no replacements", args{Params{AppJSON: AppJSONPaths{"app.json"}}, "../fixtures"}, []byte{123, 10, 32, 32, 32, 32, 34, 102, 111, 111, 34, 58, 32, 34, 98, 97, 114, 34, 10, 125, 10}, false},
+		{"Reads file with replacements", args{Params{AppJSON: AppJSONPaths{"app_template.json"}, Replacements: []Metadata{{"foo", "bar"}}}, "../fixtures"}, []byte{123, 10, 32, 32, 32, 32, 34, 102, 111, 111, 34, 58, 32, 34, 98, 97, 114, 34, 10, 125, 10}, false},
+		{"Reads file with replacement files", args{Params{AppJSON: AppJSONPaths{"app_template.json"}, ReplacementFiles: []Metadata{{"foo", "foo.txt"}}}, "../fixtures"}, []byte{123, 10, 32, 32, 32, 32, 34, 102, 111, 111, 34, 58, 32, 34, 98, 97, 114, 34, 10, 125, 10}, false},
+		{"Reads file with missing replacement files", args{Params{AppJSON: AppJSONPaths{"app_template.json"}, ReplacementFiles: []Metadata{{"foo", "baz.txt"}}}, "../fixtures"}, nil, true},
+		{"Reads file with bad tmpl", args{Params{AppJSON: AppJSONPaths{"app_template_bad.json"}, Replacements: []Metadata{{"foo", "bar"}}}, "../fixtures"}, nil, true},
+		{"Applies overlays", args{Params{AppJSON: AppJSONPaths{"app.json"}, Overlays: []string{"overlay_merge.json", "overlay_patch.json"}, Replacements: []Metadata{{"foo", "baz"}}}, "../fixtures"}, []byte(`{"foo":"baz","instances":2,"labels":{"env":"prod"}}`), false},
+		{"Fails on a bad overlay", args{Params{AppJSON: AppJSONPaths{"app.json"}, Overlays: []string{"overlay_bad.json"}}, "../fixtures"}, nil, true},
+		{"Fails on a missing overlay", args{Params{AppJSON: AppJSONPaths{"app.json"}, Overlays: []string{"nope.json"}}, "../fixtures"}, nil, true},
+		{"Reads YAML file", args{Params{AppJSON: AppJSONPaths{"app_template.yaml"}, Replacements: []Metadata{{"foo", "bar"}}}, "../fixtures"}, []byte(`{"foo":"bar"}`), false},
 	}
 	for _, tt := range tests {
-		got, err := parsePayload(tt.args.p, tt.args.path)
+		got, err := parsePayload(tt.args.p, tt.args.path, tt.args.p.AppJSON[0])
 		if (err != nil) != tt.wantErr {
 			t.Errorf("%q. parsePayload() error = %v, wantErr %v", tt.name, err, tt.wantErr)
 			continue
@@ -37,3 +43,107 @@ func Test_parsePayload(t *testing.T) {
 		}
 	}
 }
+
+func Test_appJSONFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns AppJSONPaths
+		want     []string
+		wantErr  bool
+	}{
+		{"Single file", AppJSONPaths{"app.json"}, []string{"app.json"}, false},
+		{"Glob", AppJSONPaths{"app_template*.json", "app.json", "app_template.json"}, []string{"app_template.json", "app_template_bad.json", "app.json"}, false},
+		{"Missing file", AppJSONPaths{"nope.json"}, []string{"nope.json"}, false},
+		{"Bad glob", AppJSONPaths{"[.json"}, nil, true},
+		{"Not set", nil, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := appJSONFiles(tt.patterns, "../fixtures")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. appJSONFiles() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. appJSONFiles() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_renderYAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "marathon-resource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name         string
+		template     string
+		replacements map[string]string
+		want         string
+		wantErr      string
+	}{
+		{
+			"Converts to JSON",
+			"id: /{{name}}\ninstances: {{count}}\ncmd: |\n  echo {{name}}\n",
+			map[string]string{"name": "foo", "count": "2"},
+			`{"id":"/foo","instances":2,"cmd":"echo foo\n"}`,
+			"",
+		},
+		{
+			"Multi-line double-quoted string",
+			"id: /foo\ncmd: \"echo {{name}}\n  && echo bar\"\n",
+			map[string]string{"name": "foo"},
+			`{"id":"/foo","cmd":"echo foo && echo bar"}`,
+			"",
+		},
+		{
+			"Bad indentation",
+			"id: /foo\n  instances: 1\n",
+			nil,
+			"",
+			"yaml: line 2: mapping values are not allowed in this context",
+		},
+		{
+			"Error after a multi-line replacement",
+			"id: /foo\n{{#if labels}}\nlabels: {{labels}}\n{{/if}}\ncpus: 1\n  mem: 2\n",
+			map[string]string{"labels": "\n  a: b\n  c: d\n  e: f"},
+			"",
+			"yaml: line 6: mapping values are not allowed in this context",
+		},
+		{
+			"Error in a multi-line replacement",
+			"id: /foo\nlabels: {{labels}}\ncpus: 1\n",
+			map[string]string{"labels": "\n  a: b\n   c: d"},
+			"",
+			"yaml: line 2: mapping values are not allowed in this context",
+		},
+		{
+			"Bad template",
+			"id: {{#if}}\n",
+			nil,
+			"",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(dir, "app.yml")
+		if err = ioutil.WriteFile(file, []byte(tt.template), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := renderYAML(file, tt.replacements)
+		if (err != nil) != (tt.want == "") {
+			t.Errorf("%q. renderYAML() error = %v, want %s", tt.name, err, tt.want)
+			continue
+		}
+		if err != nil {
+			if tt.wantErr != "" && err.Error() != tt.wantErr {
+				t.Errorf("%q. renderYAML() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%q. renderYAML() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
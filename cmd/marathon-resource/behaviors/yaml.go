@@ -0,0 +1,170 @@
+package behaviors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// yamlToJSON converts a YAML document to JSON. Mappings keep the order their
+// keys were written in.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	// Only a yaml.MapSlice keeps the order of the keys, and it can only hold
+	// a mapping.
+	if _, ok := doc.(map[interface{}]interface{}); ok {
+		var ordered yaml.MapSlice
+		if err := yaml.Unmarshal(data, &ordered); err != nil {
+			return nil, err
+		}
+		doc = inOrder(doc, ordered)
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// inOrder orders the mappings in v like the ones in ordered. yaml.v2 loses
+// the keys merged with `<<` when it decodes into a yaml.MapSlice, so the
+// values come from v. Merged keys follow the ones written out.
+func inOrder(v, ordered interface{}) interface{} {
+	switch x := v.(type) {
+	case []interface{}:
+		o, ok := ordered.([]interface{})
+		if !ok || len(o) != len(x) {
+			return v
+		}
+		for i := range x {
+			x[i] = inOrder(x[i], o[i])
+		}
+	case map[interface{}]interface{}:
+		o, ok := ordered.(yaml.MapSlice)
+		if !ok {
+			return v
+		}
+		m := make(yaml.MapSlice, 0, len(o))
+		written := map[interface{}]bool{}
+		for _, item := range o {
+			if e, ok := x[item.Key]; ok {
+				m = append(m, yaml.MapItem{Key: item.Key, Value: inOrder(e, item.Value)})
+				written[item.Key] = true
+			}
+		}
+		for _, item := range sortedItems(x) {
+			if !written[item.Key] {
+				m = append(m, item)
+			}
+		}
+		return m
+	}
+	return v
+}
+
+// sortedItems returns the members of m sorted by key.
+func sortedItems(m map[interface{}]interface{}) yaml.MapSlice {
+	items := make(yaml.MapSlice, 0, len(m))
+	for k, e := range m {
+		items = append(items, yaml.MapItem{Key: k, Value: e})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return fmt.Sprint(items[i].Key) < fmt.Sprint(items[j].Key)
+	})
+	return items
+}
+
+// writeJSON writes a value decoded by yaml.v2 as JSON.
+func writeJSON(buf *bytes.Buffer, v interface{}) error {
+	switch x := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(x))
+	case int:
+		buf.WriteString(strconv.Itoa(x))
+	case int64:
+		buf.WriteString(strconv.FormatInt(x, 10))
+	case uint64:
+		buf.WriteString(strconv.FormatUint(x, 10))
+	case float64:
+		if math.IsInf(x, 0) || math.IsNaN(x) {
+			return fmt.Errorf("%v can't be represented in JSON", x)
+		}
+		buf.WriteString(strconv.FormatFloat(x, 'g', -1, 64))
+	case string:
+		return writeJSONString(buf, x)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, e := range x {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case yaml.MapSlice:
+		return writeJSONObject(buf, x)
+	case map[interface{}]interface{}:
+		return writeJSONObject(buf, sortedItems(x))
+	default:
+		return fmt.Errorf("%v can't be represented in JSON", x)
+	}
+	return nil
+}
+
+func writeJSONObject(buf *bytes.Buffer, m yaml.MapSlice) error {
+	seen := map[string]bool{}
+	buf.WriteByte('{')
+	for i, item := range m {
+		var key string
+		switch k := item.Key.(type) {
+		case string:
+			key = k
+		case nil, bool, int, int64, uint64, float64:
+			key = fmt.Sprint(k)
+		default:
+			return fmt.Errorf("%v can't be used as a JSON key", k)
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicate key %q", key)
+		}
+		seen[key] = true
+
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := writeJSONString(buf, key); err != nil {
+			return err
+		}
+		buf.WriteByte(':')
+		if err := writeJSON(buf, item.Value); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// writeJSONString writes s without escaping HTML, so commands like `a && b`
+// read the same in the JSON.
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(s); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
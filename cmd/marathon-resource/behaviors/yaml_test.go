@@ -0,0 +1,50 @@
+package behaviors
+
+import "testing"
+
+func Test_yamlToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    string
+		wantErr bool
+	}{
+		{
+			"Keeps key order",
+			"id: /foo\ncmd: a && b\nenv:\n  Z: \"1\"\n  A: x\n",
+			`{"id":"/foo","cmd":"a && b","env":{"Z":"1","A":"x"}}`,
+			false,
+		},
+		{
+			"Scalar types",
+			"a: 1\nb: 0.5\nc: true\nd: null\ne: \"2\"\nf: 2017-01-01T00:00:00Z\n",
+			`{"a":1,"b":0.5,"c":true,"d":null,"e":"2","f":"2017-01-01T00:00:00Z"}`,
+			false,
+		},
+		{
+			"Anchors and aliases",
+			"base: &base\n  cpus: 1\napp:\n  <<: *base\n  mem: 64\n",
+			`{"base":{"cpus":1},"app":{"mem":64,"cpus":1}}`,
+			false,
+		},
+		{
+			"Top-level sequence",
+			"- op: remove\n  path: /cpus\n",
+			`[{"op":"remove","path":"/cpus"}]`,
+			false,
+		},
+		{"Duplicate key", "a: 1\na: 2\n", "", true},
+		{"Not representable", "a: .inf\n", "", true},
+		{"Invalid", "a: [1\n", "", true},
+	}
+	for _, tt := range tests {
+		got, err := yamlToJSON([]byte(tt.yaml))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. yamlToJSON() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%q. yamlToJSON() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -13,6 +13,11 @@ func (d Dates) Len() int           { return len(d) }
 func (d Dates) Less(i, j int) bool { return d[i].Before(d[j]) }
 func (d Dates) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
 
+//ParseTimestamp parses a Marathon version timestamp
+func ParseTimestamp(timestampString string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, timestampString)
+}
+
 //NewerTimestamps returns all timestamps in a list newer than a given timestamp
 func NewerTimestamps(
 	timestampStrings []string,
@@ -23,7 +28,7 @@ func NewerTimestamps(
 		timestamps            = make(Dates, len(timestampStrings))
 	)
 	for i, v := range timestampStrings {
-		t, err := time.Parse(time.RFC3339Nano, v)
+		t, err := ParseTimestamp(v)
 		if err != nil {
 			return nil, err
 		}
@@ -34,10 +39,7 @@ func NewerTimestamps(
 		currentTimestampString = time.Unix(0, 0).Format(time.RFC3339Nano)
 	}
 
-	currentTimestamp, err := time.Parse(
-		time.RFC3339Nano,
-		currentTimestampString,
-	)
+	currentTimestamp, err := ParseTimestamp(currentTimestampString)
 	if err != nil {
 		return nil, err
 	}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"net/url"
 	"os"
 
@@ -33,7 +33,11 @@ func main() {
 			// `WithError(error)` is hard to mock because it returns a concrete
 			// type.
 			if len(os.Getenv("GO_TESTING")) == 0 {
-				logger.WithError(err).Fatal(msg)
+				entry := logger.WithError(err)
+				if apiErr, ok := marathon.Cause(err).(*marathon.APIError); ok {
+					entry = entry.WithFields(apiErr.Fields())
+				}
+				entry.Fatal(msg)
 			}
 			panic(fmt.Sprintf("%s: %v", msg, err))
 		}
@@ -52,12 +56,32 @@ func main() {
 		logFatal(err, "Failed to decode stdin")
 	}
 
-	uri, err := url.Parse(input.Source.URI)
+	var uris []*url.URL
+	for _, rawURI := range input.Source.URI {
+		uri, err := url.Parse(rawURI)
+		if err != nil {
+			logFatal(err, fmt.Sprintf("Malformed URI %s", rawURI))
+		}
+		uris = append(uris, uri)
+	}
+	if len(uris) == 0 {
+		logFatal(errors.New("No URI"), "You must supply at least one URI")
+	}
+
+	client, err := marathon.NewHTTPClient(input.Source.TLS)
 	if err != nil {
-		logFatal(err, fmt.Sprintf("Malformed URI %s", input.Source.URI))
+		logFatal(err, "Invalid TLS configuration")
 	}
 
-	m := marathon.NewMarathoner(&http.Client{}, uri, input.Source.BasicAuth, input.Source.APIToken, logger)
+	m := marathon.NewMarathoner(
+		client,
+		uris,
+		input.Source.BasicAuth,
+		input.Source.APIToken,
+		input.Source.ServiceAccount,
+		input.Source.Retry,
+		logger,
+	)
 
 	switch os.Args[1] {
 	case check:
@@ -65,7 +89,7 @@ func main() {
 			logFatal(err, "Unable to get APP versions from marathon")
 		}
 	case in:
-		if output, err = behaviors.In(input, m); err != nil {
+		if output, err = behaviors.In(input, os.Args[2], m); err != nil {
 			logFatal(err, "Unable to get APP info from marathon")
 		}
 	case out:
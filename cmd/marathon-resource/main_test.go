@@ -25,7 +25,10 @@ func Test_main(t *testing.T) {
 		{"Bad json", args{[]string{"", "out"}, `{]`}, true},
 		{"Wrong number of args", args{[]string{""}, "{}"}, true},
 		{"Bad URI", args{[]string{"", "out"}, `{"source":{"uri":"http://192.168.0.%31/"}}`}, true},
-		{"Unknown argument", args{[]string{"", "foo"}, `{}`}, true},
+		{"Bad URI in list", args{[]string{"", "out"}, `{"source":{"uri":["http://foo.bar/","http://192.168.0.%31/"]}}`}, true},
+		{"No URI", args{[]string{"", "check"}, `{"source":{}}`}, true},
+		{"Bad TLS config", args{[]string{"", "check"}, `{"source":{"uri":"https://foo.bar/","tls":{"ca_cert":"foo"}}}`}, true},
+		{"Unknown argument", args{[]string{"", "foo"}, `{"source":{"uri":"http://foo.bar/"}}`}, true},
 	}
 	for _, tt := range tests {
 		var stdin *os.File
@@ -0,0 +1,216 @@
+package marathon
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	pathACSLogin = "/acs/api/v1/auth/login"
+
+	// loginTokenLifetime is how long the JWT sent to the ACS is valid. It
+	// is only used for the login itself.
+	loginTokenLifetime = 5 * time.Minute
+)
+
+//ServiceAccount holds the DC/OS service account used to log in to the ACS
+type ServiceAccount struct {
+	UID string `json:"uid"`
+	// PrivateKey is the PEM encoded RSA private key of the service account.
+	PrivateKey string `json:"private_key"`
+	// LoginEndpoint overrides the ACS login URL. It defaults to
+	// `/acs/api/v1/auth/login` on the host of the Marathon URI.
+	LoginEndpoint string `json:"login_endpoint"`
+}
+
+// authorization returns the value of the Authorization header. The service
+// account logs in on first use.
+func (m *marathon) authorization() (string, error) {
+	if m.apiToken != "" {
+		return fmt.Sprintf("token=%s", m.apiToken), nil
+	}
+	if m.serviceAccount == nil {
+		return "", nil
+	}
+
+	m.mu.Lock()
+	token := m.acsToken
+	m.mu.Unlock()
+	if token == "" {
+		var err error
+		if token, err = m.login(); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("token=%s", token), nil
+}
+
+// reauthenticate logs the service account in again when Marathon rejected its
+// token. It reports whether the request is worth sending again.
+func (m *marathon) reauthenticate(err error) bool {
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.StatusCode != http.StatusUnauthorized || m.serviceAccount == nil {
+		return false
+	}
+	m.logger.Info("Marathon rejected the ACS token, logging in again")
+	if _, err = m.login(); err != nil {
+		m.logger.WithError(err).Warn("Unable to log in to the ACS")
+		return false
+	}
+	return true
+}
+
+// login gets a new ACS token for the service account. The login is retried
+// and fails over like any other request to Marathon.
+func (m *marathon) login() (string, error) {
+	var (
+		token    string
+		attempts = m.retry.attemptsFor(http.MethodGet)
+	)
+	// Every configured Marathon gets a chance before giving up.
+	if attempts < len(m.urls) {
+		attempts = len(m.urls)
+	}
+	err := m.withRetries(
+		attempts,
+		logrus.Fields{"Method": http.MethodPost, "Path": pathACSLogin},
+		func() (bool, error) {
+			var (
+				retry bool
+				err   error
+			)
+			token, retry, err = m.tryLogin()
+			return retry, err
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	m.acsToken = token
+	m.mu.Unlock()
+	return token, nil
+}
+
+// tryLogin sends a single login request to the ACS. It reports whether a
+// failure is transient and the login is worth retrying.
+func (m *marathon) tryLogin() (string, bool, error) {
+	loginToken, err := m.serviceAccount.loginToken(time.Now())
+	if err != nil {
+		return "", false, err
+	}
+	payload, err := json.Marshal(map[string]string{
+		"uid":   m.serviceAccount.UID,
+		"token": loginToken,
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		m.loginEndpoint(),
+		bytes.NewReader(payload),
+	)
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("Content-type", jsonContentType)
+
+	m.logger.WithField("URL", req.URL.String()).Info(
+		"Logging in to the ACS with the service account",
+	)
+	res, err := m.client.Do(req)
+	if err != nil {
+		return "", true, err
+	}
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+	if res.StatusCode != http.StatusOK || res.Body == nil {
+		return "", isTransientStatus(res.StatusCode), newAPIError(req, res)
+	}
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&login); err != nil {
+		return "", false, err
+	}
+	if login.Token == "" {
+		return "", false, errors.New("The ACS did not return a token")
+	}
+	return login.Token, false, nil
+}
+
+func (m *marathon) loginEndpoint() string {
+	if m.serviceAccount.LoginEndpoint != "" {
+		return m.serviceAccount.LoginEndpoint
+	}
+	endpoint := m.endpoint()
+	u := url.URL{Scheme: endpoint.Scheme, Host: endpoint.Host, Path: pathACSLogin}
+	return u.String()
+}
+
+// loginToken returns an RS256 signed JWT identifying the service account.
+func (s *ServiceAccount) loginToken(now time.Time) (string, error) {
+	key, err := parseRSAPrivateKey(s.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"uid": s.UID,
+		"exp": now.Add(loginTokenLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func parseRSAPrivateKey(privateKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return nil, errors.New("No PEM encoded private key found for the service account")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("The service account private key must be an RSA key")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,136 @@
+package marathon
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+)
+
+// verifyLoginToken checks the signature and claims of a login JWT.
+func verifyLoginToken(key *rsa.PublicKey, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("Malformed token %q", token)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return "", err
+	}
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	var claims struct {
+		UID string `json:"uid"`
+		Exp int64  `json:"exp"`
+	}
+	if err = json.Unmarshal(rawClaims, &claims); err != nil {
+		return "", err
+	}
+	if claims.Exp <= time.Now().Unix() {
+		return "", fmt.Errorf("Token expired at %d", claims.Exp)
+	}
+	return claims.UID, nil
+}
+
+func Test_marathon_serviceAccount(t *testing.T) {
+	var (
+		logger, _ = test.NewNullLogger()
+		mu        sync.Mutex
+		logins    int
+		validACS  string
+	)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs1 := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	pkcs8DER, _ := x509.MarshalPKCS8PrivateKey(key)
+	pkcs8 := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8DER}))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(pathACSLogin, func(w http.ResponseWriter, r *http.Request) {
+		var login struct {
+			UID   string `json:"uid"`
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&login); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		uid, err := verifyLoginToken(&key.PublicKey, login.Token)
+		if err != nil || uid != login.UID || uid != "concourse" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"title":"Unauthorized","description":"bad login token"}`)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		logins++
+		validACS = fmt.Sprintf("acs-%d", logins)
+		fmt.Fprintf(w, `{"token":%q}`, validACS)
+	})
+	mux.HandleFunc("/marathon/v2/apps/foo/versions", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		// The first token expires after its first use.
+		if r.Header.Get("Authorization") != "token="+validACS || validACS == "acs-1" {
+			validACS = ""
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"versions":["2015-02-11T09:31:50.021Z"]}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL + "/marathon")
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL, _ := url.Parse(down.URL + "/marathon")
+	down.Close()
+
+	tests := []struct {
+		name       string
+		account    *ServiceAccount
+		urls       []*url.URL
+		wantErr    bool
+		wantLogins int
+	}{
+		{"Logs in again when the token is rejected", &ServiceAccount{UID: "concourse", PrivateKey: pkcs1}, []*url.URL{u}, false, 2},
+		{"PKCS8 key and login endpoint", &ServiceAccount{UID: "concourse", PrivateKey: pkcs8, LoginEndpoint: srv.URL + pathACSLogin}, []*url.URL{u}, false, 2},
+		{"Fails over when the ACS is unreachable", &ServiceAccount{UID: "concourse", PrivateKey: pkcs1}, []*url.URL{downURL, u}, false, 2},
+		{"Wrong uid", &ServiceAccount{UID: "foo", PrivateKey: pkcs1}, []*url.URL{u}, true, 0},
+		{"Bad key", &ServiceAccount{UID: "concourse", PrivateKey: "foo"}, []*url.URL{u}, true, 0},
+	}
+	for _, tt := range tests {
+		mu.Lock()
+		logins, validACS = 0, ""
+		mu.Unlock()
+		m := NewMarathoner(&http.Client{}, tt.urls, nil, "", tt.account, &RetryPolicy{Attempts: 1}, logger)
+		if _, err := m.LatestVersions("foo", ""); (err != nil) != tt.wantErr {
+			t.Errorf("%q. marathon.LatestVersions() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if logins != tt.wantLogins {
+			t.Errorf("%q. Expected %d logins but got %d", tt.name, tt.wantLogins, logins)
+		}
+	}
+}
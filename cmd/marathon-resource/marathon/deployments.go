@@ -0,0 +1,73 @@
+package marathon
+
+import (
+	"encoding/json"
+	"net/http"
+
+	gomarathon "github.com/gambol99/go-marathon"
+)
+
+// GetDeployment returns a running deployment. Once the deployment is done
+// Marathon no longer lists it and nil is returned.
+func (m *marathon) GetDeployment(deploymentID string) (*gomarathon.Deployment, error) {
+	var deployments []*gomarathon.Deployment
+	if err := m.handleReq(
+		http.MethodGet,
+		pathDeployments,
+		nil,
+		[]int{http.StatusOK},
+		&deployments,
+	); err != nil {
+		return nil, err
+	}
+
+	for _, d := range deployments {
+		if d.ID != deploymentID {
+			continue
+		}
+		// The steps are only used to describe the deployment, so a format
+		// we don't know doesn't stop us from waiting on it.
+		if err := decodeSteps(d); err != nil {
+			m.logger.WithError(err).WithField("Deployment", d.ID).Warn(
+				"Unable to read the steps of the deployment",
+			)
+		}
+		return d, nil
+	}
+	return nil, nil
+}
+
+// decodeSteps fills in the steps of a deployment. Older Marathons list the
+// actions of each step while newer ones wrap them in an object.
+func decodeSteps(d *gomarathon.Deployment) error {
+	if len(d.XXStepsRaw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(d.XXStepsRaw, &d.Steps); err == nil {
+		return nil
+	}
+
+	var steps []struct {
+		Actions []struct {
+			Action string `json:"action"`
+			Type   string `json:"type"`
+			App    string `json:"app"`
+		} `json:"actions"`
+	}
+	if err := json.Unmarshal(d.XXStepsRaw, &steps); err != nil {
+		return err
+	}
+	d.Steps = make([][]*gomarathon.DeploymentStep, len(steps))
+	for i, step := range steps {
+		for _, action := range step.Actions {
+			if action.Action == "" {
+				action.Action = action.Type
+			}
+			d.Steps[i] = append(
+				d.Steps[i],
+				&gomarathon.DeploymentStep{Action: action.Action, App: action.App},
+			)
+		}
+	}
+	return nil
+}
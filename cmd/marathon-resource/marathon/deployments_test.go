@@ -0,0 +1,124 @@
+package marathon
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
+	gomarathon "github.com/gambol99/go-marathon"
+	"github.com/golang/mock/gomock"
+)
+
+func Test_marathon_GetDeployment(t *testing.T) {
+	var (
+		logger, _  = test.NewNullLogger()
+		ctrl       = gomock.NewController(t)
+		mockClient = mocks.NewMockdoer(ctrl)
+		u, _       = url.Parse("http://foo.bar/")
+		respond    = func(body string) *gomock.Call {
+			return mockClient.EXPECT().Do(gomock.Any()).Times(1).Return(
+				&http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				},
+				nil,
+			)
+		}
+		steps = [][]*gomarathon.DeploymentStep{
+			{{Action: "StartApplication", App: "/foo"}},
+			{{Action: "ScaleApplication", App: "/foo"}, {Action: "ScaleApplication", App: "/bar"}},
+		}
+	)
+	defer ctrl.Finish()
+	gomock.InOrder(
+		respond(`[{"id":"other"},{"id":"foo","currentStep":1,"totalSteps":2,"steps":[
+			{"actions":[{"action":"StartApplication","app":"/foo"}]},
+			{"actions":[{"action":"ScaleApplication","app":"/foo"},{"action":"ScaleApplication","app":"/bar"}]}]}]`),
+		respond(`[{"id":"foo","steps":[
+			[{"action":"StartApplication","app":"/foo"}],
+			[{"action":"ScaleApplication","app":"/foo"},{"action":"ScaleApplication","app":"/bar"}]]}]`),
+		respond(`[{"id":"foo","steps":[
+			{"actions":[{"type":"StartApplication","app":"/foo"}]},
+			{"actions":[{"type":"ScaleApplication","app":"/foo"},{"type":"ScaleApplication","app":"/bar"}]}]}]`),
+		respond(`[{"id":"foo","steps":"unknown"}]`),
+		respond(`[{"id":"other"}]`),
+	)
+	type args struct {
+		deploymentID string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantSteps [][]*gomarathon.DeploymentStep
+		wantNil   bool
+	}{
+		{"Steps with actions", args{"foo"}, steps, false},
+		{"Steps as lists", args{"foo"}, steps, false},
+		{"Actions with types", args{"foo"}, steps, false},
+		{"Steps in an unknown format", args{"foo"}, nil, false},
+		{"Done", args{"foo"}, nil, true},
+	}
+	m := &marathon{
+		client: mockClient,
+		urls:   []*url.URL{u},
+		logger: logger,
+	}
+	for _, tt := range tests {
+		got, err := m.GetDeployment(tt.args.deploymentID)
+		if err != nil {
+			t.Errorf("%q. marathon.GetDeployment(%v) error = %v", tt.name, tt.args.deploymentID, err)
+			continue
+		}
+		if (got == nil) != tt.wantNil {
+			t.Errorf("%q. marathon.GetDeployment(%v) = %v, want nil %v", tt.name, tt.args.deploymentID, got, tt.wantNil)
+			continue
+		}
+		if got != nil && !reflect.DeepEqual(got.Steps, tt.wantSteps) {
+			t.Errorf("%q. marathon.GetDeployment(%v) steps = %v, want %v", tt.name, tt.args.deploymentID, got.Steps, tt.wantSteps)
+		}
+	}
+}
+
+func Test_marathon_diagnostics(t *testing.T) {
+	var (
+		logger, _  = test.NewNullLogger()
+		ctrl       = gomock.NewController(t)
+		mockClient = mocks.NewMockdoer(ctrl)
+		u, _       = url.Parse("http://foo.bar/")
+		respond    = func(path, body string) *gomock.Call {
+			return mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(func(req *http.Request) {
+				if req.URL.Path != path {
+					t.Errorf("Expected %s but got %s", path, req.URL.Path)
+				}
+			}).Return(
+				&http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				},
+				nil,
+			)
+		}
+		m = &marathon{
+			client: mockClient,
+			urls:   []*url.URL{u},
+			logger: logger,
+		}
+	)
+	defer ctrl.Finish()
+	gomock.InOrder(
+		respond("/v2/queue", `{"queue":[]}`),
+		respond("/v2/apps/foo/tasks", `{"tasks":[]}`),
+	)
+
+	if queue, err := m.GetQueueJSON(); err != nil || string(queue) != `{"queue":[]}` {
+		t.Errorf("marathon.GetQueueJSON() = %s, %v", queue, err)
+	}
+	if tasks, err := m.GetAppTasksJSON("foo"); err != nil || string(tasks) != `{"tasks":[]}` {
+		t.Errorf("marathon.GetAppTasksJSON() = %s, %v", tasks, err)
+	}
+}
@@ -0,0 +1,141 @@
+package marathon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// maxErrorBody limits how much of a non JSON error response is kept.
+const maxErrorBody = 512
+
+type (
+	//APIError is returned when Marathon responds with an unexpected status code
+	APIError struct {
+		StatusCode int
+		Method     string
+		Path       string
+		Message    string
+		Details    []APIErrorDetail
+		// Deployments lists the deployments locking the resource when
+		// Marathon responds with 409 Conflict.
+		Deployments []string
+	}
+
+	//APIErrorDetail holds the validation errors Marathon reports for a field
+	APIErrorDetail struct {
+		Path   string   `json:"path"`
+		Errors []string `json:"errors"`
+	}
+)
+
+//Cause returns the error underneath any errors that wrap it. An error wraps
+//another by implementing `Cause() error`, the same as github.com/pkg/errors
+func Cause(err error) error {
+	for err != nil {
+		c, ok := err.(causer)
+		if !ok {
+			break
+		}
+		err = c.Cause()
+	}
+	return err
+}
+
+// causer is implemented by errors that wrap another.
+type causer interface {
+	Cause() error
+}
+
+func newAPIError(req *http.Request, res *http.Response) *APIError {
+	apiErr := &APIError{
+		StatusCode: res.StatusCode,
+		Method:     req.Method,
+		Path:       req.URL.Path,
+	}
+	if res.Body == nil {
+		return apiErr
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil || len(body) == 0 {
+		return apiErr
+	}
+
+	var errBody struct {
+		Message     string           `json:"message"`
+		Details     []APIErrorDetail `json:"details"`
+		Deployments []struct {
+			ID string `json:"id"`
+		} `json:"deployments"`
+	}
+	if err = json.Unmarshal(body, &errBody); err != nil {
+		// Proxies in front of Marathon tend to answer with HTML or plain text.
+		if len(body) > maxErrorBody {
+			body = body[:maxErrorBody]
+		}
+		apiErr.Message = strings.TrimSpace(string(body))
+		return apiErr
+	}
+	apiErr.Message = errBody.Message
+	apiErr.Details = errBody.Details
+	for _, d := range errBody.Deployments {
+		apiErr.Deployments = append(apiErr.Deployments, d.ID)
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf(
+		"Marathon responded to %s %s with %d %s",
+		e.Method,
+		e.Path,
+		e.StatusCode,
+		http.StatusText(e.StatusCode),
+	)
+	if e.Message != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Message)
+	}
+	if len(e.Details) > 0 {
+		msg = fmt.Sprintf("%s (%s)", msg, e.details())
+	}
+	if len(e.Deployments) > 0 {
+		msg = fmt.Sprintf(
+			"%s (blocked by deployments: %s)",
+			msg,
+			strings.Join(e.Deployments, ", "),
+		)
+	}
+	return msg
+}
+
+//Fields returns the error as log fields
+func (e *APIError) Fields() logrus.Fields {
+	fields := logrus.Fields{
+		"Status": e.StatusCode,
+		"Method": e.Method,
+		"Path":   e.Path,
+	}
+	if e.Message != "" {
+		fields["Message"] = e.Message
+	}
+	if len(e.Details) > 0 {
+		fields["Details"] = e.details()
+	}
+	if len(e.Deployments) > 0 {
+		fields["Deployments"] = strings.Join(e.Deployments, ", ")
+	}
+	return fields
+}
+
+func (e *APIError) details() string {
+	details := make([]string, len(e.Details))
+	for i, d := range e.Details {
+		details[i] = fmt.Sprintf("%s: %s", d.Path, strings.Join(d.Errors, ", "))
+	}
+	return strings.Join(details, "; ")
+}
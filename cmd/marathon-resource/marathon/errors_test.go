@@ -0,0 +1,137 @@
+package marathon
+
+import (
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func Test_newAPIError(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPut, "http://foo.bar/v2/apps/foo", nil)
+	tests := []struct {
+		name       string
+		res        *http.Response
+		want       *APIError
+		wantString string
+	}{
+		{
+			"Validation error",
+			&http.Response{
+				StatusCode: http.StatusUnprocessableEntity,
+				Body: ioutil.NopCloser(strings.NewReader(
+					`{"message":"Object is not valid","details":[{"path":"/instances","errors":["error.min"]},{"path":"/cmd","errors":["a","b"]}]}`,
+				)),
+			},
+			&APIError{
+				StatusCode: http.StatusUnprocessableEntity,
+				Method:     http.MethodPut,
+				Path:       "/v2/apps/foo",
+				Message:    "Object is not valid",
+				Details: []APIErrorDetail{
+					{Path: "/instances", Errors: []string{"error.min"}},
+					{Path: "/cmd", Errors: []string{"a", "b"}},
+				},
+			},
+			"Marathon responded to PUT /v2/apps/foo with 422 Unprocessable Entity: Object is not valid (/instances: error.min; /cmd: a, b)",
+		},
+		{
+			"Locked by deployments",
+			&http.Response{
+				StatusCode: http.StatusConflict,
+				Body: ioutil.NopCloser(strings.NewReader(
+					`{"message":"App is locked by one or more deployments.","deployments":[{"id":"d1"},{"id":"d2"}]}`,
+				)),
+			},
+			&APIError{
+				StatusCode:  http.StatusConflict,
+				Method:      http.MethodPut,
+				Path:        "/v2/apps/foo",
+				Message:     "App is locked by one or more deployments.",
+				Deployments: []string{"d1", "d2"},
+			},
+			"Marathon responded to PUT /v2/apps/foo with 409 Conflict: App is locked by one or more deployments. (blocked by deployments: d1, d2)",
+		},
+		{
+			"Plain text body",
+			&http.Response{
+				StatusCode: http.StatusBadGateway,
+				Body:       ioutil.NopCloser(strings.NewReader("<html>Bad Gateway</html>\n")),
+			},
+			&APIError{
+				StatusCode: http.StatusBadGateway,
+				Method:     http.MethodPut,
+				Path:       "/v2/apps/foo",
+				Message:    "<html>Bad Gateway</html>",
+			},
+			"Marathon responded to PUT /v2/apps/foo with 502 Bad Gateway: <html>Bad Gateway</html>",
+		},
+		{
+			"No body",
+			&http.Response{StatusCode: http.StatusUnauthorized},
+			&APIError{
+				StatusCode: http.StatusUnauthorized,
+				Method:     http.MethodPut,
+				Path:       "/v2/apps/foo",
+			},
+			"Marathon responded to PUT /v2/apps/foo with 401 Unauthorized",
+		},
+	}
+	for _, tt := range tests {
+		got := newAPIError(req, tt.res)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. newAPIError() = %#v, want %#v", tt.name, got, tt.want)
+		}
+		if got.Error() != tt.wantString {
+			t.Errorf("%q. APIError.Error() = %q, want %q", tt.name, got.Error(), tt.wantString)
+		}
+	}
+}
+
+func TestAPIError_Fields(t *testing.T) {
+	err := &APIError{
+		StatusCode:  http.StatusConflict,
+		Method:      http.MethodPut,
+		Path:        "/v2/apps/foo",
+		Message:     "App is locked by one or more deployments.",
+		Details:     []APIErrorDetail{{Path: "/id", Errors: []string{"locked"}}},
+		Deployments: []string{"d1", "d2"},
+	}
+	want := logrus.Fields{
+		"Status":      http.StatusConflict,
+		"Method":      http.MethodPut,
+		"Path":        "/v2/apps/foo",
+		"Message":     "App is locked by one or more deployments.",
+		"Details":     "/id: locked",
+		"Deployments": "d1, d2",
+	}
+	if got := err.Fields(); !reflect.DeepEqual(got, want) {
+		t.Errorf("APIError.Fields() = %v, want %v", got, want)
+	}
+}
+
+type wrapped struct{ err error }
+
+func (w wrapped) Error() string { return "wrapped: " + w.err.Error() }
+func (w wrapped) Cause() error  { return w.err }
+
+func TestCause(t *testing.T) {
+	apiErr := &APIError{StatusCode: http.StatusNotFound}
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"Not wrapped", apiErr, apiErr},
+		{"Wrapped twice", wrapped{wrapped{apiErr}}, apiErr},
+		{"Nil", nil, nil},
+	}
+	for _, tt := range tests {
+		if got := Cause(tt.err); got != tt.want {
+			t.Errorf("%q. Cause() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package marathon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/donovanhide/eventsource"
+	gomarathon "github.com/gambol99/go-marathon"
+)
+
+const (
+	eventDeploymentSuccess     = "deployment_success"
+	eventDeploymentFailed      = "deployment_failed"
+	eventDeploymentStepSuccess = "deployment_step_success"
+
+	eventStreamContentType = "text/event-stream"
+)
+
+// pollInterval is how often deployments are polled when the event stream is
+// unavailable.
+var pollInterval = time.Second
+
+//ErrDeploymentFailed is returned when Marathon reports a deployment as failed
+var ErrDeploymentFailed = errors.New("Deployment failed")
+
+type (
+	deploymentEvent struct {
+		name string
+		id   string
+	}
+	eventStream struct {
+		events chan deploymentEvent
+		body   io.ReadCloser
+		done   chan struct{}
+	}
+)
+
+// WaitDeployment blocks until the deployment finishes or the time out is
+// reached. It returns true if the deployment was still running when it gave
+// up. The outcome is read from the Marathon event stream and deployments are
+// polled if the stream is unavailable.
+func (m *marathon) WaitDeployment(
+	deploymentID string,
+	timeOut time.Duration,
+) (bool, error) {
+	timer := time.NewTimer(timeOut)
+	defer timer.Stop()
+	progress := &deploymentProgress{m: m}
+
+	stream, err := m.subscribe(
+		eventDeploymentSuccess,
+		eventDeploymentFailed,
+		eventDeploymentStepSuccess,
+	)
+	if err != nil {
+		m.logger.WithError(err).Warn(
+			"Unable to subscribe to Marathon events, polling deployments instead",
+		)
+		return m.pollDeployment(deploymentID, timer.C, progress)
+	}
+	defer stream.close()
+
+	// The deployment may have finished before we subscribed.
+	d, err := m.GetDeployment(deploymentID)
+	if err != nil || d == nil {
+		return false, err
+	}
+	progress.update(d)
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return true, nil
+		case <-ticker.C:
+			// The event may have been missed or the stream stalled.
+			if progress.check(deploymentID) {
+				return false, nil
+			}
+		case ev, ok := <-stream.events:
+			if !ok {
+				m.logger.Warn(
+					"Lost the Marathon event stream, polling deployments instead",
+				)
+				return m.pollDeployment(deploymentID, timer.C, progress)
+			}
+			if ev.id != deploymentID {
+				continue
+			}
+			switch ev.name {
+			case eventDeploymentSuccess:
+				return false, nil
+			case eventDeploymentFailed:
+				return false, ErrDeploymentFailed
+			case eventDeploymentStepSuccess:
+				m.logger.WithField("Deployment", deploymentID).Info(
+					"Deployment step finished",
+				)
+				if progress.check(deploymentID) {
+					return false, nil
+				}
+			}
+		}
+	}
+}
+
+func (m *marathon) pollDeployment(
+	deploymentID string,
+	timeOut <-chan time.Time,
+	progress *deploymentProgress,
+) (bool, error) {
+	for {
+		d, err := m.GetDeployment(deploymentID)
+		if err != nil || d == nil {
+			return false, err
+		}
+		progress.update(d)
+		select {
+		case <-timeOut:
+			return true, nil
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func (m *marathon) subscribe(eventTypes ...string) (*eventStream, error) {
+	m.discoverLeader()
+	req, err := m.newRequest(http.MethodGet, pathEvents, nil)
+	if err != nil {
+		return nil, err
+	}
+	q := req.URL.Query()
+	for _, eventType := range eventTypes {
+		q.Add("event_type", eventType)
+	}
+	req.URL.RawQuery = q.Encode()
+	req.Header.Set("Accept", eventStreamContentType)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	m.logger.WithFields(
+		logrus.Fields{
+			"Method": req.Method,
+			"URL":    req.URL.String(),
+		},
+	).Info("Subscribing to Marathon events")
+	res, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res, err = m.followRedirects(req, res, nil); err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK || res.Body == nil {
+		if res.Body != nil {
+			res.Body.Close()
+		}
+		return nil, fmt.Errorf(
+			"Expected %d response code from the event stream but got %d",
+			http.StatusOK,
+			res.StatusCode,
+		)
+	}
+
+	stream := &eventStream{
+		events: make(chan deploymentEvent),
+		body:   res.Body,
+		done:   make(chan struct{}),
+	}
+	go stream.read()
+	return stream, nil
+}
+
+func (s *eventStream) read() {
+	defer close(s.events)
+	dec := eventsource.NewDecoder(s.body)
+	for {
+		ev, err := dec.Decode()
+		if err != nil {
+			return
+		}
+
+		var id string
+		switch ev.Event() {
+		case eventDeploymentSuccess:
+			var e gomarathon.EventDeploymentSuccess
+			if json.Unmarshal([]byte(ev.Data()), &e) != nil {
+				continue
+			}
+			id = e.ID
+		case eventDeploymentFailed:
+			var e gomarathon.EventDeploymentFailed
+			if json.Unmarshal([]byte(ev.Data()), &e) != nil {
+				continue
+			}
+			id = e.ID
+		case eventDeploymentStepSuccess:
+			var e gomarathon.EventDeploymentStepSuccess
+			if json.Unmarshal([]byte(ev.Data()), &e) != nil || e.Plan == nil {
+				continue
+			}
+			id = e.Plan.ID
+		default:
+			continue
+		}
+
+		select {
+		case s.events <- deploymentEvent{name: ev.Event(), id: id}:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *eventStream) close() {
+	close(s.done)
+	s.body.Close()
+}
@@ -0,0 +1,186 @@
+package marathon
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/donovanhide/eventsource"
+)
+
+type sseEvent struct {
+	name, data string
+}
+
+func (e sseEvent) Id() string    { return "" }
+func (e sseEvent) Event() string { return e.name }
+func (e sseEvent) Data() string  { return e.data }
+
+// marathonStandIn serves a minimal `/v2/events` and `/v2/deployments`. Each
+// call to `/v2/deployments` returns the next entry of deployments, repeating
+// the last one once they run out.
+func marathonStandIn(
+	t *testing.T,
+	eventsStatus int,
+	events []sseEvent,
+	deployments []string,
+) *httptest.Server {
+	var (
+		mu    sync.Mutex
+		calls int
+		mux   = http.NewServeMux()
+	)
+	mux.HandleFunc(pathEvents, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != eventStreamContentType {
+			t.Errorf("Event stream requested with Accept %q", r.Header.Get("Accept"))
+		}
+		if eventsStatus != http.StatusOK {
+			w.WriteHeader(eventsStatus)
+			return
+		}
+		w.Header().Set("Content-Type", eventStreamContentType)
+		w.WriteHeader(http.StatusOK)
+		enc := eventsource.NewEncoder(w, false)
+		for _, ev := range events {
+			if err := enc.Encode(ev); err != nil {
+				return
+			}
+		}
+		w.(http.Flusher).Flush()
+		if events != nil {
+			<-r.Context().Done()
+		}
+	})
+	mux.HandleFunc(pathDeployments, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		i := calls
+		if i >= len(deployments) {
+			i = len(deployments) - 1
+		}
+		calls++
+		fmt.Fprint(w, deployments[i])
+	})
+	return httptest.NewServer(mux)
+}
+
+func Test_marathon_WaitDeployment(t *testing.T) {
+	var (
+		logger, _ = test.NewNullLogger()
+		running   = `[{"id":"foo","steps":[]}]`
+		finished  = `[]`
+	)
+	pollInterval = 10 * time.Millisecond
+	progressInterval = 10 * time.Millisecond
+
+	tests := []struct {
+		name          string
+		eventsStatus  int
+		events        []sseEvent
+		deployments   []string
+		timeOut       time.Duration
+		wantDeploying bool
+		wantErr       error
+	}{
+		{
+			"Succeeds from the event stream",
+			http.StatusOK,
+			[]sseEvent{
+				{eventDeploymentStepSuccess, `{"eventType":"deployment_step_success","plan":{"id":"foo"}}`},
+				{eventDeploymentSuccess, `{"id":"bar","eventType":"deployment_success"}`},
+				{eventDeploymentSuccess, `{"id":"foo","eventType":"deployment_success"}`},
+			},
+			[]string{running},
+			time.Second,
+			false,
+			nil,
+		},
+		{
+			"Fails from the event stream",
+			http.StatusOK,
+			[]sseEvent{
+				{eventDeploymentFailed, `{"id":"foo","eventType":"deployment_failed"}`},
+			},
+			[]string{running},
+			time.Second,
+			false,
+			ErrDeploymentFailed,
+		},
+		{
+			"Finished before subscribing",
+			http.StatusOK,
+			[]sseEvent{},
+			[]string{finished},
+			time.Second,
+			false,
+			nil,
+		},
+		{
+			"Finishes when the deployment is gone without an event",
+			http.StatusOK,
+			[]sseEvent{},
+			[]string{running, running, finished},
+			time.Second,
+			false,
+			nil,
+		},
+		{
+			"Times out on the event stream",
+			http.StatusOK,
+			[]sseEvent{},
+			[]string{running},
+			50 * time.Millisecond,
+			true,
+			nil,
+		},
+		{
+			"Polls when the event stream is unavailable",
+			http.StatusNotFound,
+			nil,
+			[]string{running, running, finished},
+			time.Second,
+			false,
+			nil,
+		},
+		{
+			"Polls when the event stream is lost",
+			http.StatusOK,
+			nil,
+			[]string{running, running, finished},
+			time.Second,
+			false,
+			nil,
+		},
+		{
+			"Times out while polling",
+			http.StatusNotFound,
+			nil,
+			[]string{running},
+			50 * time.Millisecond,
+			true,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		srv := marathonStandIn(t, tt.eventsStatus, tt.events, tt.deployments)
+		u, _ := url.Parse(srv.URL)
+		m := &marathon{
+			client: &http.Client{},
+			urls:   []*url.URL{u},
+			logger: logger,
+		}
+		got, err := m.WaitDeployment("foo", tt.timeOut)
+		if err != tt.wantErr {
+			t.Errorf("%q. marathon.WaitDeployment() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.wantDeploying {
+			t.Errorf("%q. marathon.WaitDeployment() = %v, want %v", tt.name, got, tt.wantDeploying)
+		}
+		srv.Close()
+	}
+}
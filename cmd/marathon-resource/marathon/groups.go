@@ -0,0 +1,65 @@
+package marathon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/dates"
+	gomarathon "github.com/gambol99/go-marathon"
+)
+
+const (
+	pathGroup          = "/v2/groups/%s"
+	pathGroupVersions  = "/v2/groups/%s/versions"
+	pathGroupAtVersion = "/v2/groups/%s/versions/%s"
+)
+
+func (m *marathon) LatestGroupVersions(groupID, version string) ([]string, error) {
+	var v []string
+	if err := m.handleReq(
+		http.MethodGet,
+		fmt.Sprintf(pathGroupVersions, groupID),
+		nil,
+		[]int{http.StatusOK},
+		&v,
+	); err != nil {
+		return nil, err
+	}
+	return dates.NewerTimestamps(v, version)
+}
+
+// GetGroupJSON returns the definition of the group at a version, or of the
+// running group when no version is given.
+func (m *marathon) GetGroupJSON(groupID, version string) (json.RawMessage, error) {
+	path := fmt.Sprintf(pathGroupAtVersion, groupID, version)
+	if version == "" {
+		path = fmt.Sprintf(pathGroup, groupID)
+	}
+	var groupJSON json.RawMessage
+	err := m.handleReq(
+		http.MethodGet,
+		path,
+		nil,
+		[]int{http.StatusOK},
+		&groupJSON,
+	)
+	return groupJSON, err
+}
+
+func (m *marathon) UpdateGroup(
+	groupID string,
+	groupJSON json.RawMessage,
+	force bool,
+) (gomarathon.DeploymentID, error) {
+	var deployment gomarathon.DeploymentID
+	err := m.handleReq(
+		http.MethodPut,
+		withForce(fmt.Sprintf(pathGroup, groupID), force),
+		bytes.NewReader(groupJSON),
+		[]int{http.StatusOK, http.StatusCreated},
+		&deployment,
+	)
+	return deployment, err
+}
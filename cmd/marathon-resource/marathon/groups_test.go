@@ -0,0 +1,70 @@
+package marathon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
+	gomarathon "github.com/gambol99/go-marathon"
+	"github.com/golang/mock/gomock"
+)
+
+func Test_marathon_groups(t *testing.T) {
+	var (
+		logger, _  = test.NewNullLogger()
+		ctrl       = gomock.NewController(t)
+		mockClient = mocks.NewMockdoer(ctrl)
+		u, _       = url.Parse("http://foo.bar/")
+		respond    = func(method, path, body string) *gomock.Call {
+			return mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(func(req *http.Request) {
+				if req.Method != method || req.URL.Path != path {
+					t.Errorf("Expected %s %s but got %s %s", method, path, req.Method, req.URL.Path)
+				}
+			}).Return(
+				&http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				},
+				nil,
+			)
+		}
+		m = &marathon{
+			client: mockClient,
+			urls:   []*url.URL{u},
+			logger: logger,
+		}
+	)
+	defer ctrl.Finish()
+	gomock.InOrder(
+		respond(http.MethodGet, "/v2/groups/product/versions", `["2015-02-11T09:31:50.021Z","2014-03-01T23:42:20.938Z"]`),
+		respond(http.MethodGet, "/v2/groups/product/versions/2015-02-11T09:31:50.021Z", `{"id":"/product"}`),
+		respond(http.MethodGet, "/v2/groups/product", `{"id":"/product"}`),
+		respond(http.MethodPut, "/v2/groups/product", `{"deploymentId":"foo","version":"bar"}`),
+	)
+
+	versions, err := m.LatestGroupVersions("product", "2015-02-11T09:31:50.021Z")
+	if err != nil || !reflect.DeepEqual(versions, []string{"2015-02-11T09:31:50.021Z"}) {
+		t.Errorf("marathon.LatestGroupVersions() = %v, %v", versions, err)
+	}
+
+	group, err := m.GetGroupJSON("product", "2015-02-11T09:31:50.021Z")
+	if err != nil || string(group) != `{"id":"/product"}` {
+		t.Errorf("marathon.GetGroupJSON() = %s, %v", group, err)
+	}
+
+	group, err = m.GetGroupJSON("product", "")
+	if err != nil || string(group) != `{"id":"/product"}` {
+		t.Errorf("marathon.GetGroupJSON() = %s, %v", group, err)
+	}
+
+	did, err := m.UpdateGroup("product", json.RawMessage(`{"id":"/product"}`), false)
+	if want := (gomarathon.DeploymentID{DeploymentID: "foo", Version: "bar"}); err != nil || did != want {
+		t.Errorf("marathon.UpdateGroup() = %v, %v", did, err)
+	}
+}
@@ -0,0 +1,199 @@
+package marathon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	pathLeader = "/v2/leader"
+
+	maxRedirects = 10
+)
+
+// endpoint returns the Marathon URL requests are currently sent to.
+func (m *marathon) endpoint() *url.URL {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.urls[m.current]
+}
+
+// failover moves on to the next configured Marathon after a connection
+// failure. The leader is looked up again before the next request.
+func (m *marathon) failover() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.urls) < 2 {
+		return
+	}
+	m.current = (m.current + 1) % len(m.urls)
+	m.discovered = false
+	m.logger.WithField("URL", m.urls[m.current].String()).Warn(
+		"Failing over to the next Marathon",
+	)
+}
+
+// discoverLeader asks each configured Marathon, starting with the current
+// one, for the leader and sends requests straight to it when it is one of the
+// configured URLs. Otherwise requests go to the first Marathon that answered
+// and it proxies them to the leader.
+func (m *marathon) discoverLeader() {
+	m.mu.Lock()
+	if len(m.urls) < 2 || m.discovered {
+		m.mu.Unlock()
+		return
+	}
+	start, urls := m.current, m.urls
+	m.mu.Unlock()
+
+	for i := range urls {
+		idx := (start + i) % len(urls)
+		leader, err := m.leaderOf(urls[idx])
+		if err != nil {
+			m.logger.WithError(err).WithField("URL", urls[idx].String()).Warn(
+				"Unable to reach Marathon",
+			)
+			continue
+		}
+
+		current := idx
+		for j, u := range urls {
+			if u.Host == leader {
+				current = j
+				break
+			}
+		}
+		m.mu.Lock()
+		m.current, m.discovered = current, true
+		m.mu.Unlock()
+		m.logger.WithFields(
+			logrus.Fields{
+				"Leader": leader,
+				"URL":    urls[current].String(),
+			},
+		).Info("Discovered Marathon leader")
+		return
+	}
+}
+
+func (m *marathon) leaderOf(base *url.URL) (string, error) {
+	u := *base
+	u.Path = path.Join(u.Path, pathLeader)
+	req, err := m.newRequestURL(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := m.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+	if res.StatusCode != http.StatusOK || res.Body == nil {
+		return "", newAPIError(req, res)
+	}
+
+	var leader struct {
+		Leader string `json:"leader"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&leader); err != nil {
+		return "", err
+	}
+	return leader.Leader, nil
+}
+
+// followRedirects re-sends a request to the location a Marathon that isn't
+// the leader redirects it to, keeping its headers. The credentials are only
+// sent on when the location is one of the configured Marathons.
+func (m *marathon) followRedirects(
+	req *http.Request,
+	res *http.Response,
+	body []byte,
+) (*http.Response, error) {
+	for redirects := 0; isRedirect(res.StatusCode); redirects++ {
+		location, err := res.Location()
+		if res.Body != nil {
+			res.Body.Close()
+		}
+		if err != nil {
+			return res, err
+		}
+		if redirects >= maxRedirects {
+			return res, fmt.Errorf("Stopped after %d redirects", maxRedirects)
+		}
+
+		var payload io.Reader
+		if body != nil {
+			payload = bytes.NewReader(body)
+		}
+		next, err := http.NewRequest(req.Method, location.String(), payload)
+		if err != nil {
+			return res, err
+		}
+		for key, values := range req.Header {
+			if key != "Authorization" {
+				next.Header[key] = values
+			}
+		}
+		if m.isMaster(location) {
+			if err = m.authorize(next); err != nil {
+				return res, err
+			}
+		} else {
+			m.logger.WithField("URL", location.String()).Warn(
+				"Not sending credentials to a redirect outside the configured Marathons",
+			)
+		}
+		req = next
+
+		m.logger.WithField("URL", location.String()).Info(
+			"Following redirect to the Marathon leader",
+		)
+		if res, err = m.client.Do(req); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// isMaster reports whether u is on one of the configured Marathons.
+func (m *marathon) isMaster(u *url.URL) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, master := range m.urls {
+		if hostPort(master) == hostPort(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostPort returns the host and port of u, with the scheme's default port
+// when it has none.
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+func isRedirect(statusCode int) bool {
+	return statusCode == http.StatusTemporaryRedirect ||
+		statusCode == http.StatusPermanentRedirect
+}
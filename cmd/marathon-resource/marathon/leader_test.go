@@ -0,0 +1,225 @@
+package marathon
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
+	"github.com/golang/mock/gomock"
+)
+
+func noRedirectClient() *http.Client {
+	return &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func mustParse(t *testing.T, rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func Test_marathon_discoverLeader(t *testing.T) {
+	var (
+		logger, _ = test.NewNullLogger()
+		hits      = map[string]int{}
+		leader    = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits["leader"]++
+			fmt.Fprint(w, `{"versions":["2015-02-11T09:31:50.021Z"]}`)
+		}))
+		follower = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != pathLeader {
+				hits["follower"]++
+			}
+			fmt.Fprintf(w, `{"leader":%q}`, mustParse(t, leader.URL).Host)
+		}))
+		down = httptest.NewServer(http.NotFoundHandler())
+	)
+	defer leader.Close()
+	defer follower.Close()
+	down.Close()
+
+	m := &marathon{
+		client: noRedirectClient(),
+		urls: []*url.URL{
+			mustParse(t, down.URL),
+			mustParse(t, follower.URL),
+			mustParse(t, leader.URL),
+		},
+		retry:  RetryPolicy{Attempts: 1},
+		logger: logger,
+	}
+	if _, err := m.LatestVersions("foo", ""); err != nil {
+		t.Fatalf("marathon.LatestVersions() error = %v", err)
+	}
+	if hits["leader"] != 1 || hits["follower"] != 0 {
+		t.Errorf("Expected the request to go to the leader but got %v", hits)
+	}
+	if m.current != 2 || !m.discovered {
+		t.Errorf("Expected the leader to be remembered but got %d, %t", m.current, m.discovered)
+	}
+}
+
+func Test_marathon_failover(t *testing.T) {
+	var (
+		logger, _  = test.NewNullLogger()
+		ctrl       = gomock.NewController(t)
+		mockClient = mocks.NewMockdoer(ctrl)
+		first      = mustParse(t, "http://first.bar/")
+		second     = mustParse(t, "http://second.bar/")
+		leaderRes  = func(host string) *http.Response {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(fmt.Sprintf(`{"leader":%q}`, host))),
+			}
+		}
+		wantHost = func(host string) func(*http.Request) {
+			return func(req *http.Request) {
+				if req.URL.Host != host {
+					t.Errorf("Expected a request to %s but got %s", host, req.URL)
+				}
+			}
+		}
+	)
+	defer ctrl.Finish()
+	gomock.InOrder(
+		mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(wantHost("first.bar")).Return(leaderRes("first.bar"), nil),
+		mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(wantHost("first.bar")).Return(nil, errors.New("connection refused")),
+		mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(wantHost("second.bar")).Return(leaderRes("second.bar"), nil),
+		mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(wantHost("second.bar")).Return(
+			&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`[]`))},
+			nil,
+		),
+	)
+
+	m := &marathon{
+		client: mockClient,
+		urls:   []*url.URL{first, second},
+		retry:  RetryPolicy{Attempts: 1},
+		logger: logger,
+	}
+	if err := m.handleReq(http.MethodGet, pathDeployments, nil, []int{http.StatusOK}, nil); err != nil {
+		t.Errorf("marathon.handleReq() error = %v", err)
+	}
+}
+
+func Test_marathon_followRedirects(t *testing.T) {
+	var (
+		logger, _ = test.NewNullLogger()
+		leader    = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "foo" || pass != "bar" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			if string(body) != `{"id":"foo"}` {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"deploymentId":"bar"}`)
+		}))
+		follower = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, leader.URL+r.URL.Path, http.StatusTemporaryRedirect)
+		}))
+		loop = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, r.URL.Path, http.StatusTemporaryRedirect)
+		}))
+	)
+	defer leader.Close()
+	defer follower.Close()
+	defer loop.Close()
+
+	tests := []struct {
+		name    string
+		urls    []string
+		wantErr bool
+	}{
+		{"Keeps auth when following the leader", []string{follower.URL, leader.URL}, false},
+		{"Drops auth for other hosts", []string{follower.URL}, true},
+		{"Stops following redirects", []string{loop.URL}, true},
+	}
+	for _, tt := range tests {
+		m := &marathon{
+			client:     noRedirectClient(),
+			auth:       &AuthCreds{UserName: "foo", Password: "bar"},
+			retry:      RetryPolicy{Attempts: 1},
+			logger:     logger,
+			discovered: true,
+		}
+		for _, u := range tt.urls {
+			m.urls = append(m.urls, mustParse(t, u))
+		}
+		got, err := m.UpdateApp("foo", []byte(`{"id":"foo"}`), false)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. marathon.UpdateApp() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got.DeploymentID != "bar" {
+			t.Errorf("%q. marathon.UpdateApp() = %v, want bar", tt.name, got)
+		}
+	}
+}
+
+func Test_marathon_subscribe_followsLeader(t *testing.T) {
+	var (
+		logger, _ = test.NewNullLogger()
+		leader    = marathonStandIn(
+			t,
+			http.StatusOK,
+			[]sseEvent{{eventDeploymentSuccess, `{"id":"foo","eventType":"deployment_success"}`}},
+			[]string{`[]`},
+		)
+		follower = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, leader.URL+r.URL.String(), http.StatusTemporaryRedirect)
+		}))
+	)
+	defer leader.Close()
+	defer follower.Close()
+
+	m := &marathon{
+		client:     noRedirectClient(),
+		urls:       []*url.URL{mustParse(t, follower.URL), mustParse(t, leader.URL)},
+		logger:     logger,
+		discovered: true,
+	}
+	stream, err := m.subscribe(eventDeploymentSuccess)
+	if err != nil {
+		t.Fatalf("marathon.subscribe() error = %v", err)
+	}
+	defer stream.close()
+	if ev, ok := <-stream.events; !ok || ev.id != "foo" {
+		t.Errorf("marathon.subscribe() event = %v, want foo", ev)
+	}
+}
+
+func Test_hostPort(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{"Same host and port", "http://foo.bar:8080/", "http://foo.bar:8080/v2/apps", true},
+		{"Default http port", "http://foo.bar/", "http://foo.bar:80/", true},
+		{"Default https port", "https://FOO.bar/", "https://foo.bar:443/", true},
+		{"Other port", "http://foo.bar:8080/", "http://foo.bar:8081/", false},
+		{"Other scheme", "http://foo.bar/", "https://foo.bar/", false},
+		{"Other host", "http://foo.bar/", "http://foo.baz/", false},
+	}
+	for _, tt := range tests {
+		if got := hostPort(mustParse(t, tt.a)) == hostPort(mustParse(t, tt.b)); got != tt.want {
+			t.Errorf("%q. hostPort() equal = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
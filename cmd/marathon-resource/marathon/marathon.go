@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
+	"sync"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/dates"
@@ -15,12 +18,19 @@ import (
 )
 
 const (
+	pathApps         = "/v2/apps"
 	pathApp          = "/v2/apps/%s"
 	pathAppRestart   = "/v2/apps/%s/restart"
+	pathAppTasks     = "/v2/apps/%s/tasks"
 	pathAppVersions  = "/v2/apps/%s/versions"
 	pathAppAtVersion = "/v2/apps/%s/versions/%s"
 	pathDeployments  = "/v2/deployments"
 	pathDeployment   = "/v2/deployments/%s"
+	pathEvents       = "/v2/events"
+
+	// embedAppStatus asks Marathon to include the task counts, tasks and
+	// last task failure of a running app.
+	embedAppStatus = "embed=apps.counts&embed=apps.tasks&embed=apps.lastTaskFailure"
 
 	jsonContentType = "application/json"
 )
@@ -29,21 +39,49 @@ type (
 	doer interface {
 		Do(req *http.Request) (*http.Response, error)
 	}
+	// headerReader is implemented by response objects that need some of the
+	// response headers.
+	headerReader interface {
+		readHeader(header http.Header)
+	}
 	//Marathoner is an interface to interact with marathon
 	Marathoner interface {
 		LatestVersions(appID string, version string) ([]string, error)
 		GetApp(appID, version string) (gomarathon.Application, error)
-		UpdateApp(gomarathon.Application) (gomarathon.DeploymentID, error)
+		GetAppJSON(appID, version string) (json.RawMessage, error)
+		ListAppsJSON(labelSelector string) ([]json.RawMessage, error)
+		UpdateApp(appID string, appJSON json.RawMessage, force bool) (gomarathon.DeploymentID, error)
 		RestartApp(appID string) (gomarathon.DeploymentID, error)
+		DeleteApp(appID string) (gomarathon.DeploymentID, error)
+		ScaleApp(appID string, instances int) (gomarathon.DeploymentID, error)
+		RollbackApp(appID, version string) (gomarathon.DeploymentID, error)
 		CheckDeployment(deploymentID string) (bool, error)
-		DeleteDeployment(deploymentID string) error
+		DeleteDeployment(deploymentID string, force bool) (gomarathon.DeploymentID, error)
+		WaitDeployment(deploymentID string, timeOut time.Duration) (bool, error)
+		LatestGroupVersions(groupID string, version string) ([]string, error)
+		GetGroupJSON(groupID, version string) (json.RawMessage, error)
+		UpdateGroup(groupID string, groupJSON json.RawMessage, force bool) (gomarathon.DeploymentID, error)
+		LatestPodVersions(podID string, version string) ([]string, error)
+		GetPodJSON(podID, version string) (json.RawMessage, error)
+		GetPodStatusJSON(podID string) (json.RawMessage, error)
+		UpdatePod(podID string, podJSON json.RawMessage, force bool) (gomarathon.DeploymentID, error)
+		DeletePod(podID string) (gomarathon.DeploymentID, error)
+		GetDeployment(deploymentID string) (*gomarathon.Deployment, error)
+		GetQueueJSON() (json.RawMessage, error)
+		GetAppTasksJSON(appID string) (json.RawMessage, error)
 	}
 	marathon struct {
-		client   doer
-		url      *url.URL
-		auth     *AuthCreds
-		apiToken string
-		logger   logrus.FieldLogger
+		client         doer
+		urls           []*url.URL
+		current        int
+		discovered     bool
+		mu             sync.Mutex
+		auth           *AuthCreds
+		apiToken       string
+		serviceAccount *ServiceAccount
+		acsToken       string
+		retry          RetryPolicy
+		logger         logrus.FieldLogger
 	}
 
 	//AuthCreds will be used for HTTP basic auth
@@ -53,41 +91,129 @@ type (
 	}
 )
 
-//NewMarathoner returns a new marathoner
+//NewMarathoner returns a new marathoner. When more than one URI is given
+//requests are sent to the leader and fail over to the next URI when a
+//Marathon can't be reached.
 func NewMarathoner(
 	client doer,
-	uri *url.URL,
+	uris []*url.URL,
 	auth *AuthCreds,
 	apiToken string,
+	serviceAccount *ServiceAccount,
+	retry *RetryPolicy,
 	logger logrus.FieldLogger) Marathoner {
 	return &marathon{
-		client:   client,
-		url:      uri,
-		auth:     auth,
-		apiToken: apiToken,
-		logger:   logger,
+		client:         client,
+		urls:           uris,
+		auth:           auth,
+		apiToken:       apiToken,
+		serviceAccount: serviceAccount,
+		retry:          retry.withDefaults(),
+		logger:         logger,
 	}
 }
 
-func (m *marathon) handleReq(
+func (m *marathon) newRequest(
 	method string,
 	resourcePath string,
 	payload io.Reader,
-	wantCodes []int,
-	resObj interface{},
-) error {
-	u := *m.url
-	u.Path = path.Join(u.Path, resourcePath)
-	req, err := http.NewRequest(method, u.String(), payload)
+) (*http.Request, error) {
+	ref, err := url.Parse(resourcePath)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	// Logging in can fail over to another Marathon so it happens before the
+	// endpoint is picked.
+	if _, err = m.authorization(); err != nil {
+		return nil, err
+	}
+	u := *m.endpoint()
+	u.Path = path.Join(u.Path, ref.Path)
+	u.RawQuery = ref.RawQuery
+	return m.newRequestURL(method, u.String(), payload)
+}
+
+func (m *marathon) newRequestURL(
+	method string,
+	u string,
+	payload io.Reader,
+) (*http.Request, error) {
+	req, err := http.NewRequest(method, u, payload)
+	if err != nil {
+		return nil, err
 	}
 	req.Header.Set("Content-type", jsonContentType)
+	if err = m.authorize(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// authorize adds the configured credentials to req.
+func (m *marathon) authorize(req *http.Request) error {
 	if m.auth != nil {
 		req.SetBasicAuth(m.auth.UserName, m.auth.Password)
 	}
-	if m.apiToken != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("token=%s", m.apiToken))
+	authorization, err := m.authorization()
+	if err != nil {
+		return err
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return nil
+}
+
+func (m *marathon) handleReq(
+	method string,
+	resourcePath string,
+	payload io.Reader,
+	wantCodes []int,
+	resObj interface{},
+) error {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = ioutil.ReadAll(payload); err != nil {
+			return err
+		}
+	}
+
+	attempts := m.retry.attemptsFor(method)
+	// Every configured Marathon gets a chance before giving up.
+	if idempotentMethods[method] && attempts < len(m.urls) {
+		attempts = len(m.urls)
+	}
+	return m.withRetries(
+		attempts,
+		logrus.Fields{"Method": method, "Path": resourcePath},
+		func() (bool, error) {
+			m.discoverLeader()
+			retry, err := m.doReq(method, resourcePath, body, wantCodes, resObj)
+			if m.reauthenticate(err) {
+				retry, err = m.doReq(method, resourcePath, body, wantCodes, resObj)
+			}
+			return retry, err
+		},
+	)
+}
+
+// doReq sends a single request to Marathon. It reports whether a failure is
+// transient and the request is worth retrying.
+func (m *marathon) doReq(
+	method string,
+	resourcePath string,
+	body []byte,
+	wantCodes []int,
+	resObj interface{},
+) (bool, error) {
+	var payload io.Reader
+	if body != nil {
+		payload = bytes.NewReader(body)
+	}
+	req, err := m.newRequest(method, resourcePath, payload)
+	if err != nil {
+		return false, err
 	}
 
 	m.logger.WithFields(
@@ -95,10 +221,13 @@ func (m *marathon) handleReq(
 			"Method": req.Method,
 			"URL":    req.URL.String(),
 		},
-	).Info("Sending HTTP API request to Marathon")
+	).Debug("Sending HTTP API request to Marathon")
 	res, err := m.client.Do(req)
 	if err != nil {
-		return err
+		return true, err
+	}
+	if res, err = m.followRedirects(req, res, body); err != nil {
+		return res == nil, err
 	}
 	if res.Body != nil {
 		defer res.Body.Close()
@@ -113,21 +242,21 @@ func (m *marathon) handleReq(
 	}
 
 	if !gotWantCode {
-		return fmt.Errorf(
-			"Expected one of %v responses code but got %d",
-			wantCodes,
-			res.StatusCode,
-		)
+		return isTransientStatus(res.StatusCode), newAPIError(req, res)
+	}
+
+	if hr, ok := resObj.(headerReader); ok {
+		hr.readHeader(res.Header)
 	}
 
 	if res.Body == nil || resObj == nil {
-		return nil
+		return false, nil
 	}
 
 	if err = json.NewDecoder(res.Body).Decode(resObj); err != nil && err != io.EOF {
-		return err
+		return false, err
 	}
-	return nil
+	return false, nil
 }
 
 func (m *marathon) LatestVersions(appID, version string) ([]string, error) {
@@ -144,33 +273,90 @@ func (m *marathon) LatestVersions(appID, version string) ([]string, error) {
 	return dates.NewerTimestamps(v.Versions, version)
 }
 
+// GetApp returns the app at a version. Without a version the running app is
+// returned along with its task counts, tasks and last task failure.
 func (m *marathon) GetApp(appID, version string) (gomarathon.Application, error) {
 	var app gomarathon.Application
+	appJSON, err := m.GetAppJSON(appID, version)
+	if err != nil {
+		return app, err
+	}
+	err = json.Unmarshal(appJSON, &app)
+	return app, err
+}
+
+// GetAppJSON returns the definition of the app at a version, or of the running
+// app when no version is given.
+func (m *marathon) GetAppJSON(appID, version string) (json.RawMessage, error) {
+	if version == "" {
+		var res struct {
+			App json.RawMessage `json:"app"`
+		}
+		err := m.handleReq(
+			http.MethodGet,
+			fmt.Sprintf(pathApp, appID)+"?"+embedAppStatus,
+			nil,
+			[]int{http.StatusOK},
+			&res,
+		)
+		return res.App, err
+	}
+
+	var appJSON json.RawMessage
 	err := m.handleReq(
 		http.MethodGet,
 		fmt.Sprintf(pathAppAtVersion, appID, version),
 		nil,
 		[]int{http.StatusOK},
-		&app,
+		&appJSON,
 	)
-	return app, err
+	return appJSON, err
 }
 
-func (m *marathon) UpdateApp(inApp gomarathon.Application) (gomarathon.DeploymentID, error) {
-	var (
-		payload, _ = json.Marshal(inApp)
-		deployment gomarathon.DeploymentID
-	)
+func (m *marathon) UpdateApp(
+	appID string,
+	appJSON json.RawMessage,
+	force bool,
+) (gomarathon.DeploymentID, error) {
+	var deployment gomarathon.DeploymentID
 	err := m.handleReq(
 		http.MethodPut,
-		fmt.Sprintf(pathApp, inApp.ID),
-		bytes.NewReader(payload),
+		withForce(fmt.Sprintf(pathApp, appID), force),
+		bytes.NewReader(appJSON),
 		[]int{http.StatusOK, http.StatusCreated},
 		&deployment,
 	)
 	return deployment, err
 }
 
+func (m *marathon) GetAppTasksJSON(appID string) (json.RawMessage, error) {
+	var tasksJSON json.RawMessage
+	err := m.handleReq(
+		http.MethodGet,
+		fmt.Sprintf(pathAppTasks, appID),
+		nil,
+		[]int{http.StatusOK},
+		&tasksJSON,
+	)
+	return tasksJSON, err
+}
+
+// ListAppsJSON returns the running apps matching a label selector, like
+// `HAPROXY_DEPLOYMENT_GROUP==web`, along with their task counts.
+func (m *marathon) ListAppsJSON(labelSelector string) ([]json.RawMessage, error) {
+	var res struct {
+		Apps []json.RawMessage `json:"apps"`
+	}
+	err := m.handleReq(
+		http.MethodGet,
+		pathApps+"?label="+url.QueryEscape(labelSelector)+"&embed=apps.counts",
+		nil,
+		[]int{http.StatusOK},
+		&res,
+	)
+	return res.Apps, err
+}
+
 func (m *marathon) RestartApp(appID string) (gomarathon.DeploymentID, error) {
 	var (
 		deployment gomarathon.DeploymentID
@@ -185,6 +371,37 @@ func (m *marathon) RestartApp(appID string) (gomarathon.DeploymentID, error) {
 	return deployment, err
 }
 
+func (m *marathon) DeleteApp(appID string) (gomarathon.DeploymentID, error) {
+	var deployment gomarathon.DeploymentID
+	err := m.handleReq(
+		http.MethodDelete,
+		fmt.Sprintf(pathApp, appID),
+		nil,
+		[]int{http.StatusOK},
+		&deployment,
+	)
+	return deployment, err
+}
+
+func (m *marathon) ScaleApp(appID string, instances int) (gomarathon.DeploymentID, error) {
+	return m.UpdateApp(
+		appID,
+		json.RawMessage(fmt.Sprintf(`{"instances":%d}`, instances)),
+		false,
+	)
+}
+
+// RollbackApp redeploys the app as it was at a previous version.
+func (m *marathon) RollbackApp(appID, version string) (gomarathon.DeploymentID, error) {
+	rollback, err := json.Marshal(struct {
+		Version string `json:"version"`
+	}{version})
+	if err != nil {
+		return gomarathon.DeploymentID{}, err
+	}
+	return m.UpdateApp(appID, rollback, false)
+}
+
 func (m *marathon) CheckDeployment(deploymentID string) (bool, error) {
 	var (
 		deployments []gomarathon.Deployment
@@ -205,12 +422,29 @@ func (m *marathon) CheckDeployment(deploymentID string) (bool, error) {
 	return false, err
 }
 
-func (m *marathon) DeleteDeployment(deploymentID string) error {
-	return m.handleReq(
+// DeleteDeployment cancels a deployment. Marathon rolls the affected apps
+// back with a new deployment which is returned. A forced cancel stops the
+// deployment where it is without a rollback.
+func (m *marathon) DeleteDeployment(
+	deploymentID string,
+	force bool,
+) (gomarathon.DeploymentID, error) {
+	var rollback gomarathon.DeploymentID
+	err := m.handleReq(
 		http.MethodDelete,
-		fmt.Sprintf(pathDeployment, deploymentID),
-		nil,
-		[]int{http.StatusOK},
+		withForce(fmt.Sprintf(pathDeployment, deploymentID), force),
 		nil,
+		[]int{http.StatusOK, http.StatusAccepted},
+		&rollback,
 	)
+	return rollback, err
+}
+
+// withForce adds the query Marathon needs to override the lock another
+// deployment holds.
+func withForce(resourcePath string, force bool) string {
+	if !force {
+		return resourcePath
+	}
+	return resourcePath + "?force=true"
 }
@@ -62,7 +62,7 @@ func Test_marathon_LatestVersions(t *testing.T) {
 	for _, tt := range tests {
 		m := &marathon{
 			client: tt.fields.client,
-			url:    tt.fields.url,
+			urls:   []*url.URL{tt.fields.url},
 			logger: logger,
 		}
 		got, err := m.LatestVersions(tt.args.appID, tt.args.version)
@@ -94,7 +94,7 @@ func Test_marathon_handleReq(t *testing.T) {
 	mockClient.EXPECT().Do(gomock.Any()).Times(1).Return(
 		&http.Response{
 			StatusCode: http.StatusBadRequest,
-			Body:       ioutil.NopCloser(strings.NewReader(`{"foo":"bar"}`)),
+			Body:       ioutil.NopCloser(strings.NewReader(`{"message":"Object is not valid"}`)),
 		},
 		nil,
 	)
@@ -203,7 +203,7 @@ func Test_marathon_handleReq(t *testing.T) {
 	for _, tt := range tests {
 		m := &marathon{
 			client: tt.fields.client,
-			url:    tt.fields.url,
+			urls:   []*url.URL{tt.fields.url},
 			auth:   tt.fields.auth,
 			logger: logger,
 		}
@@ -222,12 +222,30 @@ func Test_marathon_GetApp(t *testing.T) {
 	)
 	defer ctrl.Finish()
 	in, _ := json.Marshal(gomarathon.Application{ID: "hello-app"})
-	mockClient.EXPECT().Do(gomock.Any()).Times(1).Return(
-		&http.Response{
-			StatusCode: http.StatusOK,
-			Body:       ioutil.NopCloser(bytes.NewReader(in)),
-		},
-		nil,
+	gomock.InOrder(
+		mockClient.EXPECT().Do(gomock.Any()).Times(1).Return(
+			&http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(in)),
+			},
+			nil,
+		),
+		mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(func(req *http.Request) {
+			if req.URL.Path != "/v2/apps/hello-app" {
+				t.Errorf("GetApp sent request to %s", req.URL.Path)
+			}
+			if embed := req.URL.Query()["embed"]; !reflect.DeepEqual(embed, []string{"apps.counts", "apps.tasks", "apps.lastTaskFailure"}) {
+				t.Errorf("GetApp embedded %v", embed)
+			}
+		}).Return(
+			&http.Response{
+				StatusCode: http.StatusOK,
+				Body: ioutil.NopCloser(strings.NewReader(
+					`{"app":{"id":"hello-app","tasksHealthy":2,"tasks":[{"id":"t1"}]}}`,
+				)),
+			},
+			nil,
+		),
 	)
 	type fields struct {
 		client doer
@@ -245,11 +263,18 @@ func Test_marathon_GetApp(t *testing.T) {
 		wantErr bool
 	}{
 		{"Works", fields{mockClient, u}, args{"hello-app", "2015-02-11T09:31:50.021Z"}, gomarathon.Application{ID: "hello-app"}, false},
+		{
+			"Works with the running app",
+			fields{mockClient, u},
+			args{"hello-app", ""},
+			gomarathon.Application{ID: "hello-app", TasksHealthy: 2, Tasks: []*gomarathon.Task{{ID: "t1"}}},
+			false,
+		},
 	}
 	for _, tt := range tests {
 		m := &marathon{
 			client: tt.fields.client,
-			url:    tt.fields.url,
+			urls:   []*url.URL{tt.fields.url},
 			logger: logger,
 		}
 		got, err := m.GetApp(tt.args.appID, tt.args.version)
@@ -269,22 +294,39 @@ func Test_marathon_UpdateApp(t *testing.T) {
 		ctrl       = gomock.NewController(t)
 		mockClient = mocks.NewMockdoer(ctrl)
 		u, _       = url.Parse("http://foo.bar/")
+		appJSON    = json.RawMessage(`{"id":"foo-app","portDefinitions":[{"port":0}],"secrets":{"s":{"source":"x"}}}`)
 	)
 	defer ctrl.Finish()
 	out, _ := json.Marshal(gomarathon.DeploymentID{DeploymentID: "foo"})
-	mockClient.EXPECT().Do(gomock.Any()).Times(1).Return(
-		&http.Response{
-			StatusCode: http.StatusOK,
-			Body:       ioutil.NopCloser(bytes.NewReader(out)),
-		},
-		nil,
-	)
+	for _, query := range []string{"", "force=true"} {
+		query := query
+		mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(func(req *http.Request) {
+			if req.URL.Path != "/v2/apps/foo-app" {
+				t.Errorf("UpdateApp sent request to %s", req.URL.Path)
+			}
+			if req.URL.RawQuery != query {
+				t.Errorf("UpdateApp sent query %q, want %q", req.URL.RawQuery, query)
+			}
+			body, _ := ioutil.ReadAll(req.Body)
+			if !bytes.Equal(body, appJSON) {
+				t.Errorf("UpdateApp sent body %s, want %s", body, appJSON)
+			}
+		}).Return(
+			&http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(out)),
+			},
+			nil,
+		)
+	}
 	type fields struct {
 		client doer
 		url    *url.URL
 	}
 	type args struct {
-		inApp gomarathon.Application
+		appID   string
+		appJSON json.RawMessage
+		force   bool
 	}
 	tests := []struct {
 		name    string
@@ -293,21 +335,22 @@ func Test_marathon_UpdateApp(t *testing.T) {
 		want    gomarathon.DeploymentID
 		wantErr bool
 	}{
-		{"Works", fields{mockClient, u}, args{gomarathon.Application{ID: "foo-app"}}, gomarathon.DeploymentID{DeploymentID: "foo"}, false},
+		{"Works", fields{mockClient, u}, args{"foo-app", appJSON, false}, gomarathon.DeploymentID{DeploymentID: "foo"}, false},
+		{"Works with force", fields{mockClient, u}, args{"foo-app", appJSON, true}, gomarathon.DeploymentID{DeploymentID: "foo"}, false},
 	}
 	for _, tt := range tests {
 		m := &marathon{
 			client: tt.fields.client,
-			url:    tt.fields.url,
+			urls:   []*url.URL{tt.fields.url},
 			logger: logger,
 		}
-		got, err := m.UpdateApp(tt.args.inApp)
+		got, err := m.UpdateApp(tt.args.appID, tt.args.appJSON, tt.args.force)
 		if (err != nil) != tt.wantErr {
-			t.Errorf("%q. marathon.UpdateApp(%v) error = %v, wantErr %v", tt.name, tt.args.inApp, err, tt.wantErr)
+			t.Errorf("%q. marathon.UpdateApp(%v) error = %v, wantErr %v", tt.name, tt.args.appID, err, tt.wantErr)
 			continue
 		}
 		if !reflect.DeepEqual(got, tt.want) {
-			t.Errorf("%q. marathon.UpdateApp(%v) = %v, want %v", tt.name, tt.args.inApp, got, tt.want)
+			t.Errorf("%q. marathon.UpdateApp(%v) = %v, want %v", tt.name, tt.args.appID, got, tt.want)
 		}
 	}
 }
@@ -347,7 +390,7 @@ func Test_marathon_RestartApp(t *testing.T) {
 	for _, tt := range tests {
 		m := &marathon{
 			client: tt.fields.client,
-			url:    tt.fields.url,
+			urls:   []*url.URL{tt.fields.url},
 			logger: logger,
 		}
 		got, err := m.RestartApp(tt.args.inApp)
@@ -360,6 +403,164 @@ func Test_marathon_RestartApp(t *testing.T) {
 		}
 	}
 }
+
+func Test_marathon_DeleteApp(t *testing.T) {
+	var (
+		logger, _  = test.NewNullLogger()
+		ctrl       = gomock.NewController(t)
+		mockClient = mocks.NewMockdoer(ctrl)
+		u, _       = url.Parse("http://foo.bar/")
+	)
+	defer ctrl.Finish()
+	out, _ := json.Marshal(gomarathon.DeploymentID{DeploymentID: "foo"})
+	gomock.InOrder(
+		mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(func(req *http.Request) {
+			if req.Method != http.MethodDelete || req.URL.Path != "/v2/apps/foo-app" {
+				t.Errorf("marathon.DeleteApp() sent %s %s", req.Method, req.URL.Path)
+			}
+		}).Return(
+			&http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(out)),
+			},
+			nil,
+		),
+		mockClient.EXPECT().Do(gomock.Any()).Times(1).Return(
+			&http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"message":"App '/bar-app' does not exist"}`)),
+			},
+			nil,
+		),
+	)
+	type fields struct {
+		client doer
+		url    *url.URL
+	}
+	type args struct {
+		inApp string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    gomarathon.DeploymentID
+		wantErr bool
+	}{
+		{"Works", fields{mockClient, u}, args{"foo-app"}, gomarathon.DeploymentID{DeploymentID: "foo"}, false},
+		{"Missing app", fields{mockClient, u}, args{"bar-app"}, gomarathon.DeploymentID{}, true},
+	}
+	for _, tt := range tests {
+		m := &marathon{
+			client: tt.fields.client,
+			urls:   []*url.URL{tt.fields.url},
+			logger: logger,
+		}
+		got, err := m.DeleteApp(tt.args.inApp)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. marathon.DeleteApp(%v) error = %v, wantErr %v", tt.name, tt.args.inApp, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. marathon.DeleteApp(%v) = %v, want %v", tt.name, tt.args.inApp, got, tt.want)
+		}
+	}
+}
+
+func Test_marathon_ScaleApp(t *testing.T) {
+	var (
+		logger, _  = test.NewNullLogger()
+		ctrl       = gomock.NewController(t)
+		mockClient = mocks.NewMockdoer(ctrl)
+		u, _       = url.Parse("http://foo.bar/")
+	)
+	defer ctrl.Finish()
+	out, _ := json.Marshal(gomarathon.DeploymentID{DeploymentID: "foo", Version: "bar"})
+	mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(func(req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if req.Method != http.MethodPut || req.URL.Path != "/v2/apps/foo-app" || string(body) != `{"instances":3}` {
+			t.Errorf("marathon.ScaleApp() sent %s %s %s", req.Method, req.URL.Path, body)
+		}
+	}).Return(
+		&http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewReader(out)),
+		},
+		nil,
+	)
+	m := &marathon{
+		client: mockClient,
+		urls:   []*url.URL{u},
+		logger: logger,
+	}
+	got, err := m.ScaleApp("foo-app", 3)
+	if want := (gomarathon.DeploymentID{DeploymentID: "foo", Version: "bar"}); err != nil || got != want {
+		t.Errorf("marathon.ScaleApp() = %v, %v, want %v", got, err, want)
+	}
+}
+
+func Test_marathon_ListAppsJSON(t *testing.T) {
+	var (
+		logger, _  = test.NewNullLogger()
+		ctrl       = gomock.NewController(t)
+		mockClient = mocks.NewMockdoer(ctrl)
+		u, _       = url.Parse("http://foo.bar/")
+	)
+	defer ctrl.Finish()
+	mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(func(req *http.Request) {
+		if req.URL.Path != "/v2/apps" || req.URL.Query().Get("label") != "HAPROXY_DEPLOYMENT_GROUP==foo" {
+			t.Errorf("marathon.ListAppsJSON() sent %s %s", req.Method, req.URL)
+		}
+	}).Return(
+		&http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"apps":[{"id":"/foo-blue"}]}`)),
+		},
+		nil,
+	)
+	m := &marathon{
+		client: mockClient,
+		urls:   []*url.URL{u},
+		logger: logger,
+	}
+	got, err := m.ListAppsJSON("HAPROXY_DEPLOYMENT_GROUP==foo")
+	if err != nil || len(got) != 1 || string(got[0]) != `{"id":"/foo-blue"}` {
+		t.Errorf("marathon.ListAppsJSON() = %s, %v", got, err)
+	}
+}
+
+func Test_marathon_RollbackApp(t *testing.T) {
+	var (
+		logger, _  = test.NewNullLogger()
+		ctrl       = gomock.NewController(t)
+		mockClient = mocks.NewMockdoer(ctrl)
+		u, _       = url.Parse("http://foo.bar/")
+	)
+	defer ctrl.Finish()
+	out, _ := json.Marshal(gomarathon.DeploymentID{DeploymentID: "foo", Version: "bar"})
+	mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(func(req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if req.Method != http.MethodPut || req.URL.Path != "/v2/apps/foo-app" || string(body) != `{"version":"2017-01-01T00:00:00.000Z"}` {
+			t.Errorf("marathon.RollbackApp() sent %s %s %s", req.Method, req.URL.Path, body)
+		}
+	}).Return(
+		&http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewReader(out)),
+		},
+		nil,
+	)
+	m := &marathon{
+		client: mockClient,
+		urls:   []*url.URL{u},
+		logger: logger,
+	}
+	got, err := m.RollbackApp("foo-app", "2017-01-01T00:00:00.000Z")
+	if want := (gomarathon.DeploymentID{DeploymentID: "foo", Version: "bar"}); err != nil || got != want {
+		t.Errorf("marathon.RollbackApp() = %v, %v, want %v", got, err, want)
+	}
+}
+
 func Test_marathon_CheckDeployment(t *testing.T) {
 	var (
 		logger, _  = test.NewNullLogger()
@@ -396,7 +597,7 @@ func Test_marathon_CheckDeployment(t *testing.T) {
 	for _, tt := range tests {
 		m := &marathon{
 			client: tt.fields.client,
-			url:    tt.fields.url,
+			urls:   []*url.URL{tt.fields.url},
 			logger: logger,
 		}
 		got, err := m.CheckDeployment(tt.args.deploymentID)
@@ -418,19 +619,39 @@ func Test_marathon_DeleteDeployment(t *testing.T) {
 		u, _       = url.Parse("http://foo.bar/")
 	)
 	defer ctrl.Finish()
-	mockClient.EXPECT().Do(gomock.Any()).Times(1).Return(
-		&http.Response{
-			StatusCode: http.StatusOK,
-			Body:       ioutil.NopCloser(strings.NewReader("")),
-		},
-		nil,
-	)
-	mockClient.EXPECT().Do(gomock.Any()).Times(1).Return(
-		&http.Response{
-			StatusCode: http.StatusOK,
-			Body:       nil,
-		},
-		nil,
+	gomock.InOrder(
+		mockClient.EXPECT().Do(gomock.Any()).Times(1).Return(
+			&http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+			},
+			nil,
+		),
+		mockClient.EXPECT().Do(gomock.Any()).Times(1).Return(
+			&http.Response{
+				StatusCode: http.StatusOK,
+				Body:       nil,
+			},
+			nil,
+		),
+		mockClient.EXPECT().Do(gomock.Any()).Times(1).Return(
+			&http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"deploymentId":"bar","version":"baz"}`)),
+			},
+			nil,
+		),
+		mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(func(req *http.Request) {
+			if req.URL.RawQuery != "force=true" {
+				t.Errorf("DeleteDeployment sent query %q, want force=true", req.URL.RawQuery)
+			}
+		}).Return(
+			&http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+			},
+			nil,
+		),
 	)
 	type fields struct {
 		client doer
@@ -438,24 +659,33 @@ func Test_marathon_DeleteDeployment(t *testing.T) {
 	}
 	type args struct {
 		deploymentID string
+		force        bool
 	}
 	tests := []struct {
 		name    string
 		fields  fields
 		args    args
+		want    gomarathon.DeploymentID
 		wantErr bool
 	}{
-		{"Works with empty string in return body", fields{mockClient, u}, args{"foo"}, false},
-		{"Works with nil in return body", fields{mockClient, u}, args{"foo"}, false},
+		{"Works with empty string in return body", fields{mockClient, u}, args{"foo", false}, gomarathon.DeploymentID{}, false},
+		{"Works with nil in return body", fields{mockClient, u}, args{"foo", false}, gomarathon.DeploymentID{}, false},
+		{"Returns the rollback", fields{mockClient, u}, args{"foo", false}, gomarathon.DeploymentID{DeploymentID: "bar", Version: "baz"}, false},
+		{"Works forced", fields{mockClient, u}, args{"foo", true}, gomarathon.DeploymentID{}, false},
 	}
 	for _, tt := range tests {
 		m := &marathon{
 			client: tt.fields.client,
-			url:    tt.fields.url,
+			urls:   []*url.URL{tt.fields.url},
 			logger: logger,
 		}
-		if err := m.DeleteDeployment(tt.args.deploymentID); (err != nil) != tt.wantErr {
+		got, err := m.DeleteDeployment(tt.args.deploymentID, tt.args.force)
+		if (err != nil) != tt.wantErr {
 			t.Errorf("%q. marathon.DeleteDeployment(%v) error = %v, wantErr %v", tt.name, tt.args.deploymentID, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q. marathon.DeleteDeployment(%v) = %v, want %v", tt.name, tt.args.deploymentID, got, tt.want)
 		}
 	}
 }
@@ -464,7 +694,7 @@ func Test_newMarathoner(t *testing.T) {
 	var logger, _ = test.NewNullLogger()
 	type args struct {
 		client doer
-		uri    *url.URL
+		uris   []*url.URL
 		logger logrus.FieldLogger
 	}
 	tests := []struct {
@@ -472,10 +702,19 @@ func Test_newMarathoner(t *testing.T) {
 		args args
 		want Marathoner
 	}{
-		{"Works", args{http.DefaultClient, &url.URL{}, logger}, &marathon{http.DefaultClient, &url.URL{}, nil, "", logger}},
+		{
+			"Works",
+			args{http.DefaultClient, []*url.URL{{}}, logger},
+			&marathon{
+				client: http.DefaultClient,
+				urls:   []*url.URL{{}},
+				retry:  RetryPolicy{Attempts: defaultRetryAttempts, MaxBackoff: defaultRetryMaxBackoff},
+				logger: logger,
+			},
+		},
 	}
 	for _, tt := range tests {
-		if got := NewMarathoner(tt.args.client, tt.args.uri, nil, "", logger); !reflect.DeepEqual(got, tt.want) {
+		if got := NewMarathoner(tt.args.client, tt.args.uris, nil, "", nil, nil, logger); !reflect.DeepEqual(got, tt.want) {
 			t.Errorf("%q. NewMarathoner() = %v, want %v", tt.name, got, tt.want)
 		}
 	}
@@ -0,0 +1,112 @@
+package marathon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/dates"
+	gomarathon "github.com/gambol99/go-marathon"
+)
+
+const (
+	pathPod          = "/v2/pods/%s"
+	pathPodVersions  = "/v2/pods/%s::versions"
+	pathPodAtVersion = "/v2/pods/%s::versions/%s"
+	pathPodStatus    = "/v2/pods/%s::status"
+
+	headerDeploymentID = "Marathon-Deployment-Id"
+)
+
+// podDeployment reads the deployment started by a pod request. Unlike apps
+// and groups Marathon returns the pod itself and sends the deployment ID as a
+// header.
+type podDeployment struct {
+	DeploymentID string `json:"-"`
+	Version      string `json:"version"`
+}
+
+func (p *podDeployment) readHeader(header http.Header) {
+	p.DeploymentID = header.Get(headerDeploymentID)
+}
+
+func (p podDeployment) deploymentID() gomarathon.DeploymentID {
+	return gomarathon.DeploymentID{
+		DeploymentID: p.DeploymentID,
+		Version:      p.Version,
+	}
+}
+
+func (m *marathon) LatestPodVersions(podID, version string) ([]string, error) {
+	var v []string
+	if err := m.handleReq(
+		http.MethodGet,
+		fmt.Sprintf(pathPodVersions, podID),
+		nil,
+		[]int{http.StatusOK},
+		&v,
+	); err != nil {
+		return nil, err
+	}
+	return dates.NewerTimestamps(v, version)
+}
+
+// GetPodJSON returns the definition of the pod at a version, or of the
+// running pod when no version is given.
+func (m *marathon) GetPodJSON(podID, version string) (json.RawMessage, error) {
+	path := fmt.Sprintf(pathPodAtVersion, podID, version)
+	if version == "" {
+		path = fmt.Sprintf(pathPod, podID)
+	}
+	var podJSON json.RawMessage
+	err := m.handleReq(
+		http.MethodGet,
+		path,
+		nil,
+		[]int{http.StatusOK},
+		&podJSON,
+	)
+	return podJSON, err
+}
+
+// GetPodStatusJSON returns the status of a pod and its instances.
+func (m *marathon) GetPodStatusJSON(podID string) (json.RawMessage, error) {
+	var statusJSON json.RawMessage
+	err := m.handleReq(
+		http.MethodGet,
+		fmt.Sprintf(pathPodStatus, podID),
+		nil,
+		[]int{http.StatusOK},
+		&statusJSON,
+	)
+	return statusJSON, err
+}
+
+func (m *marathon) UpdatePod(
+	podID string,
+	podJSON json.RawMessage,
+	force bool,
+) (gomarathon.DeploymentID, error) {
+	var deployment podDeployment
+	err := m.handleReq(
+		http.MethodPut,
+		withForce(fmt.Sprintf(pathPod, podID), force),
+		bytes.NewReader(podJSON),
+		[]int{http.StatusOK, http.StatusCreated},
+		&deployment,
+	)
+	return deployment.deploymentID(), err
+}
+
+func (m *marathon) DeletePod(podID string) (gomarathon.DeploymentID, error) {
+	var deployment podDeployment
+	err := m.handleReq(
+		http.MethodDelete,
+		fmt.Sprintf(pathPod, podID),
+		nil,
+		[]int{http.StatusAccepted},
+		&deployment,
+	)
+	return deployment.deploymentID(), err
+}
@@ -0,0 +1,86 @@
+package marathon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
+	gomarathon "github.com/gambol99/go-marathon"
+	"github.com/golang/mock/gomock"
+)
+
+func Test_marathon_pods(t *testing.T) {
+	var (
+		logger, _  = test.NewNullLogger()
+		ctrl       = gomock.NewController(t)
+		mockClient = mocks.NewMockdoer(ctrl)
+		u, _       = url.Parse("http://foo.bar/")
+		respond    = func(method, path string, status int, body string) *gomock.Call {
+			return mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(func(req *http.Request) {
+				if req.Method != method || req.URL.Path != path {
+					t.Errorf("Expected %s %s but got %s %s", method, path, req.Method, req.URL.Path)
+				}
+				if method == http.MethodPut && req.URL.RawQuery != "force=true" {
+					t.Errorf("Expected a forced update but got query %q", req.URL.RawQuery)
+				}
+			}).Return(
+				&http.Response{
+					StatusCode: status,
+					Header:     http.Header{headerDeploymentID: []string{"foo"}},
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				},
+				nil,
+			)
+		}
+		m = &marathon{
+			client: mockClient,
+			urls:   []*url.URL{u},
+			logger: logger,
+		}
+	)
+	defer ctrl.Finish()
+	gomock.InOrder(
+		respond(http.MethodGet, "/v2/pods/web::versions", http.StatusOK, `["2015-02-11T09:31:50.021Z","2014-03-01T23:42:20.938Z"]`),
+		respond(http.MethodGet, "/v2/pods/web::versions/2015-02-11T09:31:50.021Z", http.StatusOK, `{"id":"/web"}`),
+		respond(http.MethodGet, "/v2/pods/web", http.StatusOK, `{"id":"/web"}`),
+		respond(http.MethodGet, "/v2/pods/web::status", http.StatusOK, `{"id":"/web","status":"DEGRADED"}`),
+		respond(http.MethodPut, "/v2/pods/web", http.StatusCreated, `{"id":"/web","version":"bar"}`),
+		respond(http.MethodDelete, "/v2/pods/web", http.StatusAccepted, ``),
+	)
+
+	versions, err := m.LatestPodVersions("web", "")
+	if want := []string{"2014-03-01T23:42:20.938Z", "2015-02-11T09:31:50.021Z"}; err != nil || !reflect.DeepEqual(versions, want) {
+		t.Errorf("marathon.LatestPodVersions() = %v, %v", versions, err)
+	}
+
+	pod, err := m.GetPodJSON("web", "2015-02-11T09:31:50.021Z")
+	if err != nil || string(pod) != `{"id":"/web"}` {
+		t.Errorf("marathon.GetPodJSON() = %s, %v", pod, err)
+	}
+
+	pod, err = m.GetPodJSON("web", "")
+	if err != nil || string(pod) != `{"id":"/web"}` {
+		t.Errorf("marathon.GetPodJSON() = %s, %v", pod, err)
+	}
+
+	status, err := m.GetPodStatusJSON("web")
+	if err != nil || string(status) != `{"id":"/web","status":"DEGRADED"}` {
+		t.Errorf("marathon.GetPodStatusJSON() = %s, %v", status, err)
+	}
+
+	did, err := m.UpdatePod("web", json.RawMessage(`{"id":"/web"}`), true)
+	if want := (gomarathon.DeploymentID{DeploymentID: "foo", Version: "bar"}); err != nil || did != want {
+		t.Errorf("marathon.UpdatePod() = %v, %v", did, err)
+	}
+
+	did, err = m.DeletePod("web")
+	if want := (gomarathon.DeploymentID{DeploymentID: "foo"}); err != nil || did != want {
+		t.Errorf("marathon.DeletePod() = %v, %v", did, err)
+	}
+}
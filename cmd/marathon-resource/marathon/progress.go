@@ -0,0 +1,89 @@
+package marathon
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	gomarathon "github.com/gambol99/go-marathon"
+)
+
+// progressInterval is how often the progress of a deployment is checked
+// while waiting on the event stream.
+var progressInterval = 5 * time.Second
+
+// deploymentProgress logs the progress of a deployment whenever it changes.
+type deploymentProgress struct {
+	m    *marathon
+	last string
+}
+
+// check fetches the deployment and logs its progress. It reports whether the
+// deployment is gone, meaning it finished. Progress is only informational so
+// failing to get it is ignored.
+func (p *deploymentProgress) check(deploymentID string) bool {
+	d, err := p.m.GetDeployment(deploymentID)
+	if err != nil {
+		return false
+	}
+	p.update(d)
+	return d == nil
+}
+
+// update logs the progress of a running deployment when its step, actions or
+// the task counts of the affected apps changed.
+func (p *deploymentProgress) update(d *gomarathon.Deployment) {
+	if d == nil {
+		return
+	}
+
+	fields := logrus.Fields{
+		"Deployment": d.ID,
+		"Step":       fmt.Sprintf("%d of %d", d.CurrentStep, d.TotalSteps),
+	}
+	if len(d.CurrentActions) > 0 {
+		fields["Actions"] = DescribeActions(d.CurrentActions)
+	}
+
+	var tasks []string
+	for _, appID := range d.AffectedApps {
+		app, err := p.m.GetApp(appID, "")
+		if err != nil {
+			continue
+		}
+		instances := 0
+		if app.Instances != nil {
+			instances = *app.Instances
+		}
+		tasks = append(tasks, fmt.Sprintf(
+			"%s %d staged, %d running, %d healthy of %d",
+			appID,
+			app.TasksStaged,
+			app.TasksRunning,
+			app.TasksHealthy,
+			instances,
+		))
+	}
+	if len(tasks) > 0 {
+		fields["Tasks"] = strings.Join(tasks, "; ")
+	}
+
+	summary := fmt.Sprint(fields)
+	if summary == p.last {
+		return
+	}
+	p.last = summary
+	p.m.logger.WithFields(fields).Info("Deployment progress")
+}
+
+//DescribeActions lists deployment actions with the apps they affect
+func DescribeActions(actions []*gomarathon.DeploymentStep) string {
+	described := make([]string, 0, len(actions))
+	for _, a := range actions {
+		if a != nil {
+			described = append(described, fmt.Sprintf("%s %s", a.Action, a.App))
+		}
+	}
+	return strings.Join(described, ", ")
+}
@@ -0,0 +1,102 @@
+package marathon
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/Sirupsen/logrus/hooks/test"
+)
+
+func Test_marathon_WaitDeployment_progress(t *testing.T) {
+	var (
+		logger, hook = test.NewNullLogger()
+		mu           sync.Mutex
+		calls        int
+		appCalls     int
+		deployments  = []string{
+			`[{"id":"foo","currentStep":1,"totalSteps":2,"affectedApps":["/app"],"currentActions":[{"action":"StartApplication","app":"/app"}]}]`,
+			`[{"id":"foo","currentStep":1,"totalSteps":2,"affectedApps":["/app"],"currentActions":[{"action":"StartApplication","app":"/app"}]}]`,
+			`[{"id":"foo","currentStep":2,"totalSteps":2,"affectedApps":["/app"],"currentActions":[{"action":"ScaleApplication","app":"/app"}]}]`,
+			`[{"id":"foo","currentStep":2,"totalSteps":2,"affectedApps":["/app"],"currentActions":[{"action":"ScaleApplication","app":"/app"}]}]`,
+			`[]`,
+		}
+		mux = http.NewServeMux()
+	)
+	pollInterval = 10 * time.Millisecond
+
+	mux.HandleFunc(pathEvents, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc(pathDeployments, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		i := calls
+		if i >= len(deployments) {
+			i = len(deployments) - 1
+		}
+		calls++
+		fmt.Fprint(w, deployments[i])
+	})
+	mux.HandleFunc("/v2/apps/app", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		appCalls++
+		running := 3
+		if appCalls == 1 {
+			running = 2
+		}
+		mu.Unlock()
+		fmt.Fprintf(w, `{"app":{"id":"/app","instances":3,"tasksStaged":1,"tasksRunning":%d,"tasksHealthy":1}}`, running)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	m := &marathon{
+		client: &http.Client{},
+		urls:   []*url.URL{u},
+		logger: logger,
+	}
+	if deploying, err := m.WaitDeployment("foo", time.Second); err != nil || deploying {
+		t.Fatalf("marathon.WaitDeployment() = %v, %v", deploying, err)
+	}
+
+	var got []logrus.Fields
+	for _, e := range hook.Entries {
+		if e.Message == "Deployment progress" {
+			got = append(got, e.Data)
+		}
+	}
+	want := []logrus.Fields{
+		{
+			"Deployment": "foo",
+			"Step":       "1 of 2",
+			"Actions":    "StartApplication /app",
+			"Tasks":      "/app 1 staged, 2 running, 1 healthy of 3",
+		},
+		{
+			"Deployment": "foo",
+			"Step":       "1 of 2",
+			"Actions":    "StartApplication /app",
+			"Tasks":      "/app 1 staged, 3 running, 1 healthy of 3",
+		},
+		{
+			"Deployment": "foo",
+			"Step":       "2 of 2",
+			"Actions":    "ScaleApplication /app",
+			"Tasks":      "/app 1 staged, 3 running, 1 healthy of 3",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("marathon.WaitDeployment() reported progress %v, want %v", got, want)
+	}
+	if appCalls != 4 {
+		t.Errorf("marathon.WaitDeployment() fetched the app %d times, want once per check", appCalls)
+	}
+}
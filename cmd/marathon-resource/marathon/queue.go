@@ -0,0 +1,20 @@
+package marathon
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const pathQueue = "/v2/queue"
+
+func (m *marathon) GetQueueJSON() (json.RawMessage, error) {
+	var queueJSON json.RawMessage
+	err := m.handleReq(
+		http.MethodGet,
+		pathQueue,
+		nil,
+		[]int{http.StatusOK},
+		&queueJSON,
+	)
+	return queueJSON, err
+}
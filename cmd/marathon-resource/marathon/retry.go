@@ -0,0 +1,119 @@
+package marathon
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	defaultRetryAttempts   = 3
+	defaultRetryMaxBackoff = 10
+)
+
+var (
+	// retryBaseBackoff is the upper bound of the wait before the first retry.
+	// It doubles with each attempt until it reaches the max backoff.
+	retryBaseBackoff = 500 * time.Millisecond
+
+	transientStatusCodes = map[int]bool{
+		http.StatusBadGateway:         true,
+		http.StatusServiceUnavailable: true,
+		http.StatusGatewayTimeout:     true,
+	}
+
+	idempotentMethods = map[string]bool{
+		http.MethodGet:     true,
+		http.MethodHead:    true,
+		http.MethodPut:     true,
+		http.MethodDelete:  true,
+		http.MethodOptions: true,
+	}
+)
+
+//RetryPolicy controls how transient Marathon failures are retried
+type RetryPolicy struct {
+	// Attempts is the total number of times a request is tried.
+	Attempts int `json:"attempts"`
+	// MaxBackoff is the most time, in seconds, to wait between attempts.
+	MaxBackoff int `json:"max_backoff"`
+}
+
+func (r *RetryPolicy) withDefaults() RetryPolicy {
+	policy := RetryPolicy{
+		Attempts:   defaultRetryAttempts,
+		MaxBackoff: defaultRetryMaxBackoff,
+	}
+	if r == nil {
+		return policy
+	}
+	if r.Attempts > 0 {
+		policy.Attempts = r.Attempts
+	}
+	if r.MaxBackoff > 0 {
+		policy.MaxBackoff = r.MaxBackoff
+	}
+	return policy
+}
+
+func (r RetryPolicy) attemptsFor(method string) int {
+	if r.Attempts < 1 || !idempotentMethods[method] {
+		return 1
+	}
+	return r.Attempts
+}
+
+// backoff returns a random wait of up to base * 2^attempt, capped by the max
+// backoff.
+func (r RetryPolicy) backoff(attempt int) time.Duration {
+	var (
+		maxBackoff = time.Duration(r.MaxBackoff) * time.Second
+		ceiling    = retryBaseBackoff << uint(attempt)
+	)
+	if ceiling <= 0 || ceiling > maxBackoff {
+		ceiling = maxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// withRetries calls send until it succeeds, fails for good or runs out of
+// attempts. send reports whether a failure is worth retrying. Connection
+// failures move on to the next configured Marathon.
+func (m *marathon) withRetries(
+	attempts int,
+	fields logrus.Fields,
+	send func() (bool, error),
+) error {
+	var (
+		err   error
+		retry bool
+	)
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			wait := m.retry.backoff(attempt - 1)
+			m.logger.WithFields(fields).WithFields(
+				logrus.Fields{
+					"Attempt": attempt + 1,
+					"Wait":    wait.String(),
+				},
+			).WithError(err).Warn("Retrying HTTP API request to Marathon")
+			time.Sleep(wait)
+		}
+		if retry, err = send(); !retry {
+			return err
+		}
+		if _, ok := err.(*APIError); !ok {
+			m.failover()
+		}
+	}
+	return err
+}
+
+func isTransientStatus(statusCode int) bool {
+	return transientStatusCodes[statusCode]
+}
@@ -0,0 +1,148 @@
+package marathon
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/ckaznocha/marathon-resource/cmd/marathon-resource/mocks"
+	"github.com/golang/mock/gomock"
+)
+
+func Test_marathon_handleReq_retries(t *testing.T) {
+	var (
+		logger, _ = test.NewNullLogger()
+		u, _      = url.Parse("http://foo.bar/")
+		policy    = RetryPolicy{Attempts: 3, MaxBackoff: 1}
+		status    = func(code int) *http.Response {
+			return &http.Response{
+				StatusCode: code,
+				Body:       ioutil.NopCloser(strings.NewReader(`{}`)),
+			}
+		}
+		connErr = errors.New("connection reset by peer")
+	)
+	retryBaseBackoff = time.Millisecond
+
+	type result struct {
+		res *http.Response
+		err error
+	}
+	tests := []struct {
+		name    string
+		method  string
+		payload string
+		results []result
+		wantErr bool
+	}{
+		{
+			"Retries a transient status",
+			http.MethodGet,
+			"",
+			[]result{{status(http.StatusBadGateway), nil}, {status(http.StatusOK), nil}},
+			false,
+		},
+		{
+			"Retries a connection error and resends the payload",
+			http.MethodPut,
+			`{"id":"foo"}`,
+			[]result{{nil, connErr}, {status(http.StatusGatewayTimeout), nil}, {status(http.StatusOK), nil}},
+			false,
+		},
+		{
+			"Gives up after the last attempt",
+			http.MethodGet,
+			"",
+			[]result{
+				{status(http.StatusServiceUnavailable), nil},
+				{status(http.StatusServiceUnavailable), nil},
+				{status(http.StatusServiceUnavailable), nil},
+			},
+			true,
+		},
+		{
+			"Does not retry a non transient status",
+			http.MethodGet,
+			"",
+			[]result{{status(http.StatusUnprocessableEntity), nil}},
+			true,
+		},
+		{
+			"Does not retry a non idempotent request",
+			http.MethodPost,
+			"",
+			[]result{{nil, connErr}},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		ctrl := gomock.NewController(t)
+		mockClient := mocks.NewMockdoer(ctrl)
+		var calls []*gomock.Call
+		for _, r := range tt.results {
+			calls = append(calls, mockClient.EXPECT().Do(gomock.Any()).Times(1).Do(func(req *http.Request) {
+				var body []byte
+				if req.Body != nil {
+					body, _ = ioutil.ReadAll(req.Body)
+				}
+				if string(body) != tt.payload {
+					t.Errorf("%q. handleReq() sent %q, want %q", tt.name, body, tt.payload)
+				}
+			}).Return(r.res, r.err))
+		}
+		gomock.InOrder(calls...)
+
+		m := &marathon{
+			client: mockClient,
+			urls:   []*url.URL{u},
+			retry:  policy,
+			logger: logger,
+		}
+		var payload io.Reader
+		if tt.payload != "" {
+			payload = strings.NewReader(tt.payload)
+		}
+		if err := m.handleReq(tt.method, "/", payload, []int{http.StatusOK}, nil); (err != nil) != tt.wantErr {
+			t.Errorf("%q. handleReq() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestRetryPolicy_withDefaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *RetryPolicy
+		want   RetryPolicy
+	}{
+		{"Not set", nil, RetryPolicy{defaultRetryAttempts, defaultRetryMaxBackoff}},
+		{"Partially set", &RetryPolicy{Attempts: 5}, RetryPolicy{5, defaultRetryMaxBackoff}},
+		{"Set", &RetryPolicy{Attempts: 1, MaxBackoff: 2}, RetryPolicy{1, 2}},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.withDefaults(); got != tt.want {
+			t.Errorf("%q. RetryPolicy.withDefaults() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	retryBaseBackoff = 500 * time.Millisecond
+	policy := RetryPolicy{Attempts: 10, MaxBackoff: 2}
+	for attempt := 0; attempt < 10; attempt++ {
+		ceiling := retryBaseBackoff << uint(attempt)
+		if ceiling > 2*time.Second {
+			ceiling = 2 * time.Second
+		}
+		if got := policy.backoff(attempt); got < 0 || got >= ceiling {
+			t.Errorf("RetryPolicy.backoff(%d) = %v, want [0, %v)", attempt, got, ceiling)
+		}
+	}
+	retryBaseBackoff = time.Millisecond
+}
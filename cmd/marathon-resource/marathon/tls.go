@@ -0,0 +1,84 @@
+package marathon
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+//TLSConfig holds the settings used to reach Marathon over TLS
+type TLSConfig struct {
+	// CACert is a PEM encoded bundle of the CAs to trust.
+	CACert string `json:"ca_cert"`
+	// ClientCert and ClientKey are a PEM encoded certificate and key to use
+	// for mutual TLS.
+	ClientCert string `json:"client_cert"`
+	ClientKey  string `json:"client_key"`
+	// ServerName overrides the host name used to verify Marathon's
+	// certificate.
+	ServerName         string `json:"server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+//NewHTTPClient returns a client to pass to NewMarathoner. Redirects are left
+//to the marathoner so it can keep the auth headers when following them.
+func NewHTTPClient(tlsConfig *TLSConfig) (*http.Client, error) {
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	if tlsConfig == nil {
+		return client, nil
+	}
+
+	config, err := tlsConfig.config()
+	if err != nil {
+		return nil, err
+	}
+	// The same settings as http.DefaultTransport.
+	client.Transport = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       config,
+	}
+	return client, nil
+}
+
+func (t *TLSConfig) config() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(t.CACert)) {
+			return nil, errors.New("No valid certificates found in ca_cert")
+		}
+		config.RootCAs = pool
+	}
+
+	if (t.ClientCert == "") != (t.ClientKey == "") {
+		return nil, errors.New("client_cert and client_key must be set together")
+	}
+	if t.ClientCert != "" {
+		cert, err := tls.X509KeyPair([]byte(t.ClientCert), []byte(t.ClientKey))
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,125 @@
+package marathon
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+)
+
+func selfSignedCert(t *testing.T) (certPEM, keyPEM []byte, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "concourse"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		cert
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	var (
+		logger, _                     = test.NewNullLogger()
+		clientCert, clientKey, caCert = selfSignedCert(t)
+		otherCert, _, _               = selfSignedCert(t)
+		handler                       = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"versions":["2015-02-11T09:31:50.021Z"]}`)
+		})
+		srv  = httptest.NewUnstartedServer(handler)
+		mtls = httptest.NewUnstartedServer(handler)
+		// Failed handshakes are expected, keep them out of the test output.
+		quiet = log.New(ioutil.Discard, "", 0)
+	)
+	srv.Config.ErrorLog = quiet
+	srv.StartTLS()
+	defer srv.Close()
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(caCert)
+	mtls.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	mtls.Config.ErrorLog = quiet
+	mtls.StartTLS()
+	defer mtls.Close()
+
+	serverCA := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+
+	tests := []struct {
+		name       string
+		url        string
+		config     *TLSConfig
+		wantErr    bool
+		wantReqErr bool
+	}{
+		{"No TLS config", srv.URL, nil, false, true},
+		{"Custom CA", srv.URL, &TLSConfig{CACert: serverCA}, false, false},
+		{"Wrong CA", srv.URL, &TLSConfig{CACert: string(otherCert)}, false, true},
+		{"Server name override", srv.URL, &TLSConfig{CACert: serverCA, ServerName: "example.com"}, false, false},
+		{"Wrong server name", srv.URL, &TLSConfig{CACert: serverCA, ServerName: "foo.bar"}, false, true},
+		{"Insecure", srv.URL, &TLSConfig{InsecureSkipVerify: true}, false, false},
+		{
+			"Mutual TLS",
+			mtls.URL,
+			&TLSConfig{CACert: serverCA, ClientCert: string(clientCert), ClientKey: string(clientKey)},
+			false,
+			false,
+		},
+		{"Mutual TLS without a client cert", mtls.URL, &TLSConfig{CACert: serverCA}, false, true},
+		{"Bad CA", srv.URL, &TLSConfig{CACert: "foo"}, true, false},
+		{"Client cert without key", srv.URL, &TLSConfig{ClientCert: string(clientCert)}, true, false},
+		{"Bad client key", srv.URL, &TLSConfig{ClientCert: string(clientCert), ClientKey: "foo"}, true, false},
+	}
+	for _, tt := range tests {
+		client, err := NewHTTPClient(tt.config)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. NewHTTPClient() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+
+		u, _ := url.Parse(tt.url)
+		m := NewMarathoner(client, []*url.URL{u}, nil, "", nil, &RetryPolicy{Attempts: 1}, logger)
+		if _, err = m.LatestVersions("foo", ""); (err != nil) != tt.wantReqErr {
+			t.Errorf("%q. marathon.LatestVersions() error = %v, wantErr %v", tt.name, err, tt.wantReqErr)
+		}
+	}
+}
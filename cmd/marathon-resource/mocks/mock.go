@@ -4,7 +4,9 @@
 package mocks
 
 import (
+	json "encoding/json"
 	http "net/http"
+	time "time"
 
 	go_marathon "github.com/gambol99/go-marathon"
 	gomock "github.com/golang/mock/gomock"
@@ -92,16 +94,28 @@ func (_mr *_MockMarathonerRecorder) GetApp(arg0, arg1 interface{}) *gomock.Call
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetApp", arg0, arg1)
 }
 
+// GetAppJSON ...
+func (_m *MockMarathoner) GetAppJSON(appID string, version string) (json.RawMessage, error) {
+	ret := _m.ctrl.Call(_m, "GetAppJSON", appID, version)
+	ret0, _ := ret[0].(json.RawMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) GetAppJSON(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAppJSON", arg0, arg1)
+}
+
 // UpdateApp ...
-func (_m *MockMarathoner) UpdateApp(_param0 go_marathon.Application) (go_marathon.DeploymentID, error) {
-	ret := _m.ctrl.Call(_m, "UpdateApp", _param0)
+func (_m *MockMarathoner) UpdateApp(appID string, appJSON json.RawMessage, force bool) (go_marathon.DeploymentID, error) {
+	ret := _m.ctrl.Call(_m, "UpdateApp", appID, appJSON, force)
 	ret0, _ := ret[0].(go_marathon.DeploymentID)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockMarathonerRecorder) UpdateApp(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateApp", arg0)
+func (_mr *_MockMarathonerRecorder) UpdateApp(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateApp", arg0, arg1, arg2)
 }
 
 // RestartApp ...
@@ -116,6 +130,42 @@ func (_mr *_MockMarathonerRecorder) RestartApp(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "RestartApp", arg0)
 }
 
+// DeleteApp ...
+func (_m *MockMarathoner) DeleteApp(appID string) (go_marathon.DeploymentID, error) {
+	ret := _m.ctrl.Call(_m, "DeleteApp", appID)
+	ret0, _ := ret[0].(go_marathon.DeploymentID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) DeleteApp(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteApp", arg0)
+}
+
+// ScaleApp ...
+func (_m *MockMarathoner) ScaleApp(appID string, instances int) (go_marathon.DeploymentID, error) {
+	ret := _m.ctrl.Call(_m, "ScaleApp", appID, instances)
+	ret0, _ := ret[0].(go_marathon.DeploymentID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) ScaleApp(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ScaleApp", arg0, arg1)
+}
+
+// RollbackApp ...
+func (_m *MockMarathoner) RollbackApp(appID string, version string) (go_marathon.DeploymentID, error) {
+	ret := _m.ctrl.Call(_m, "RollbackApp", appID, version)
+	ret0, _ := ret[0].(go_marathon.DeploymentID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) RollbackApp(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RollbackApp", arg0, arg1)
+}
+
 // CheckDeployment ...
 func (_m *MockMarathoner) CheckDeployment(deploymentID string) (bool, error) {
 	ret := _m.ctrl.Call(_m, "CheckDeployment", deploymentID)
@@ -129,12 +179,169 @@ func (_mr *_MockMarathonerRecorder) CheckDeployment(arg0 interface{}) *gomock.Ca
 }
 
 // DeleteDeployment ...
-func (_m *MockMarathoner) DeleteDeployment(deploymentID string) error {
-	ret := _m.ctrl.Call(_m, "DeleteDeployment", deploymentID)
-	ret0, _ := ret[0].(error)
-	return ret0
+func (_m *MockMarathoner) DeleteDeployment(deploymentID string, force bool) (go_marathon.DeploymentID, error) {
+	ret := _m.ctrl.Call(_m, "DeleteDeployment", deploymentID, force)
+	ret0, _ := ret[0].(go_marathon.DeploymentID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) DeleteDeployment(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteDeployment", arg0, arg1)
+}
+
+// WaitDeployment ...
+func (_m *MockMarathoner) WaitDeployment(deploymentID string, timeOut time.Duration) (bool, error) {
+	ret := _m.ctrl.Call(_m, "WaitDeployment", deploymentID, timeOut)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) WaitDeployment(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "WaitDeployment", arg0, arg1)
+}
+
+// LatestGroupVersions ...
+func (_m *MockMarathoner) LatestGroupVersions(groupID string, version string) ([]string, error) {
+	ret := _m.ctrl.Call(_m, "LatestGroupVersions", groupID, version)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) LatestGroupVersions(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestGroupVersions", arg0, arg1)
+}
+
+// GetGroupJSON ...
+func (_m *MockMarathoner) GetGroupJSON(groupID string, version string) (json.RawMessage, error) {
+	ret := _m.ctrl.Call(_m, "GetGroupJSON", groupID, version)
+	ret0, _ := ret[0].(json.RawMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) GetGroupJSON(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetGroupJSON", arg0, arg1)
+}
+
+// UpdateGroup ...
+func (_m *MockMarathoner) UpdateGroup(groupID string, groupJSON json.RawMessage, force bool) (go_marathon.DeploymentID, error) {
+	ret := _m.ctrl.Call(_m, "UpdateGroup", groupID, groupJSON, force)
+	ret0, _ := ret[0].(go_marathon.DeploymentID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) UpdateGroup(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateGroup", arg0, arg1, arg2)
+}
+
+// LatestPodVersions ...
+func (_m *MockMarathoner) LatestPodVersions(podID string, version string) ([]string, error) {
+	ret := _m.ctrl.Call(_m, "LatestPodVersions", podID, version)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) LatestPodVersions(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestPodVersions", arg0, arg1)
+}
+
+// GetPodJSON ...
+func (_m *MockMarathoner) GetPodJSON(podID string, version string) (json.RawMessage, error) {
+	ret := _m.ctrl.Call(_m, "GetPodJSON", podID, version)
+	ret0, _ := ret[0].(json.RawMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) GetPodJSON(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetPodJSON", arg0, arg1)
+}
+
+// UpdatePod ...
+func (_m *MockMarathoner) UpdatePod(podID string, podJSON json.RawMessage, force bool) (go_marathon.DeploymentID, error) {
+	ret := _m.ctrl.Call(_m, "UpdatePod", podID, podJSON, force)
+	ret0, _ := ret[0].(go_marathon.DeploymentID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) UpdatePod(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdatePod", arg0, arg1, arg2)
+}
+
+// DeletePod ...
+func (_m *MockMarathoner) DeletePod(podID string) (go_marathon.DeploymentID, error) {
+	ret := _m.ctrl.Call(_m, "DeletePod", podID)
+	ret0, _ := ret[0].(go_marathon.DeploymentID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) DeletePod(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeletePod", arg0)
+}
+
+// GetDeployment ...
+func (_m *MockMarathoner) GetDeployment(deploymentID string) (*go_marathon.Deployment, error) {
+	ret := _m.ctrl.Call(_m, "GetDeployment", deploymentID)
+	ret0, _ := ret[0].(*go_marathon.Deployment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) GetDeployment(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetDeployment", arg0)
+}
+
+// ListAppsJSON ...
+func (_m *MockMarathoner) ListAppsJSON(labelSelector string) ([]json.RawMessage, error) {
+	ret := _m.ctrl.Call(_m, "ListAppsJSON", labelSelector)
+	ret0, _ := ret[0].([]json.RawMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) ListAppsJSON(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListAppsJSON", arg0)
+}
+
+// GetPodStatusJSON ...
+func (_m *MockMarathoner) GetPodStatusJSON(podID string) (json.RawMessage, error) {
+	ret := _m.ctrl.Call(_m, "GetPodStatusJSON", podID)
+	ret0, _ := ret[0].(json.RawMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) GetPodStatusJSON(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetPodStatusJSON", arg0)
+}
+
+// GetQueueJSON ...
+func (_m *MockMarathoner) GetQueueJSON() (json.RawMessage, error) {
+	ret := _m.ctrl.Call(_m, "GetQueueJSON")
+	ret0, _ := ret[0].(json.RawMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMarathonerRecorder) GetQueueJSON() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetQueueJSON")
+}
+
+// GetAppTasksJSON ...
+func (_m *MockMarathoner) GetAppTasksJSON(appID string) (json.RawMessage, error) {
+	ret := _m.ctrl.Call(_m, "GetAppTasksJSON", appID)
+	ret0, _ := ret[0].(json.RawMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-func (_mr *_MockMarathonerRecorder) DeleteDeployment(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteDeployment", arg0)
+func (_mr *_MockMarathonerRecorder) GetAppTasksJSON(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAppTasksJSON", arg0)
 }